@@ -22,7 +22,8 @@ func Connect() {
 		}
 
 		fn := filepath.Join(dir, "db")
-		Conn, err = sql.Open(dbtype, "file://"+fn)
+		// the game writes in the background, so wait for locks instead of failing right away
+		Conn, err = sql.Open(dbtype, "file://"+fn+"?_pragma=busy_timeout(5000)")
 		if err != nil {
 			log.Fatalf("opening db: %v", err)
 		}
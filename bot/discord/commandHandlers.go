@@ -1,172 +1,1416 @@
 package discord
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/gabrieleiro/olx-bets/bot/db"
 	"github.com/gabrieleiro/olx-bets/bot/game"
 	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
 
-type AggregatedScore struct {
-	Username string
-	Score    int
+// interactionGame finds the game a command is about: the game of the
+// channel it was used in, or else the main game of the guild
+func interactionGame(i *discordgo.InteractionCreate) (int, error) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		return 0, err
+	}
+
+	channelId, err := strconv.Atoi(i.ChannelID)
+	if err != nil {
+		return 0, err
+	}
+
+	if id, ok := game.GameIn(guildId, channelId); ok {
+		return id, nil
+	}
+
+	return guildId, nil
+}
+
+func anuncio(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if !game.IsChannelSet(gameId) {
+		RespondInteractionWithEmbed(i, "Por favor, configure o canal do bot usando o comando **/canal**")
+		return
+	}
+
+	if !game.HasAd(gameId) {
+		err = game.StartRound(gameId)
+		if err != nil {
+			log.Println(err)
+			RespondInteractionWithEmbed(i, ops)
+			return
+		}
+	}
+
+	go RespondInteractionWithAd(s, i, game.Ad(gameId))
+}
+
+func pular(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if !game.IsChannelSet(gameId) {
+		go RespondInteractionWithEmbed(i, "Por favor, configure o canal do bot usando o comando **/canal**")
+		return
+	}
+
+	number := game.RoundNumber(gameId)
+
+	// admins skip right away, without a vote nor waiting for the cooldown
+	if i.Member.Permissions&adminPermission != 0 {
+		err = game.SkipRound(gameId)
+		if !skipVoteError(i, gameId, err) {
+			return
+		}
+
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Rodada #%d pulada!", number))
+		return
+	}
+
+	vote, err := game.StartSkipVote(gameId, i.Member.User.Username)
+	if !skipVoteError(i, gameId, err) {
+		return
+	}
+
+	if vote.Passed {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Rodada #%d pulada!", number))
+		return
+	}
+
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: skipVoteMessage(i.Member.User.Username, vote),
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+		}
+	}()
+}
+
+// skipVoteError responds to the errors of a vote to skip a round,
+// telling whether there was none and the vote can go on
+func skipVoteError(i *discordgo.InteractionCreate, gameId int, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, game.ErrSkipCooldown):
+		left := game.SkipCooldownLeft(gameId).Round(time.Second)
+		go RespondInteractionPrivately(i, fmt.Sprintf("Uma rodada foi pulada agora há pouco. Espere %s para pular de novo", left))
+	case errors.Is(err, game.ErrSkipVoteOpen):
+		go RespondInteractionPrivately(i, "Já tem uma votação para pular essa rodada, é só votar nela")
+	case errors.Is(err, game.ErrAlreadyVoted):
+		go RespondInteractionPrivately(i, "Você já votou para pular essa rodada")
+	case errors.Is(err, game.ErrNoSkipVote):
+		go RespondInteractionPrivately(i, "Essa votação já acabou")
+	case errors.Is(err, game.ErrRoundClosed):
+		go RespondInteractionPrivately(i, "Não tem nenhuma rodada para pular agora")
+	default:
+		log.Printf("voting to skip round of guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, "Não consegui escolher um anuncio novo :(")
+	}
+
+	return false
+}
+
+// skipVoteMessage is the message of a vote to skip a round, with the button to vote
+func skipVoteMessage(starter string, vote game.SkipVote) *discordgo.InteractionResponseData {
+	closesAt := time.Now().Add(game.SkipVoteDuration).Unix()
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{{
+			Description: fmt.Sprintf("%s quer pular a rodada #%d. A votação fecha <t:%d:R>", starter, vote.Round, closesAt),
+		}},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					skipVoteButton(vote),
+				},
+			},
+		},
+	}
+}
+
+func skipVoteButton(vote game.SkipVote) discordgo.Button {
+	return discordgo.Button{
+		Label:    fmt.Sprintf("Pular (%d/%d)", vote.Votes, vote.Needed),
+		Style:    discordgo.PrimaryButton,
+		CustomID: fmt.Sprintf("pular:%d", vote.Round),
+	}
+}
+
+// votarPular is a click on the button of a vote to skip a round
+func votarPular(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	_, roundStr, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+	round, err := strconv.Atoi(roundStr)
+	if err != nil {
+		log.Printf("could not parse round of skip vote: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	vote, err := game.VoteSkip(gameId, i.Member.User.Username, round)
+	if !skipVoteError(i, gameId, err) {
+		return
+	}
+
+	data := &discordgo.InteractionResponseData{
+		Embeds: i.Message.Embeds,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					skipVoteButton(vote),
+				},
+			},
+		},
+	}
+
+	if vote.Passed {
+		data.Embeds = []*discordgo.MessageEmbed{{
+			Description: fmt.Sprintf("Votação aprovada! Rodada #%d pulada", vote.Round),
+		}}
+		data.Components = []discordgo.MessageComponent{}
+	}
+
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: data,
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+		}
+	}()
+}
+
+func votosParaPular(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	votes := int(i.ApplicationCommandData().Options[0].IntValue())
+
+	err = game.SetSkipVotes(gameId, votes)
+	if errors.Is(err, game.ErrInvalidSkipVotes) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Escolha de 1 a %d votos", game.MaxSkipVotes))
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not set skip votes for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! Agora pular uma rodada precisa de %d votos", votes))
+}
+
+func canal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+	options := i.ApplicationCommandData().Options
+
+	channelId, err := strconv.Atoi(options[0].ChannelValue(s).ID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	err = game.SetChannel(guildId, channelId)
+	if errors.Is(err, game.ErrChannelTaken) {
+		go RespondInteractionWithEmbed(i, "Esse canal já tem um jogo próprio. Use **/remover_canal** antes de fazer dele o canal principal")
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not set channel for guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, "Canal do bot configurado!")
+}
+
+func topicos(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	enabled := i.ApplicationCommandData().Options[0].BoolValue()
+
+	err = game.SetRoundThreads(gameId, enabled)
+	if err != nil {
+		log.Printf("could not set round threads for game %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if enabled {
+		go RespondInteractionWithEmbed(i, "Feito! A partir da próxima rodada, cada rodada ganha um tópico, e os chutes são feitos lá")
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, "Feito! A partir da próxima rodada, os chutes voltam a ser feitos no canal")
+}
+
+func novoCanal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	var channelId int
+	var separateRanking bool
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "canal":
+			channelId, err = strconv.Atoi(opt.ChannelValue(s).ID)
+			if err != nil {
+				log.Printf("could not parse channel id: %v\n", err)
+				go RespondInteractionWithEmbed(i, ops)
+				return
+			}
+		case "ranking_separado":
+			separateRanking = opt.BoolValue()
+		}
+	}
+
+	err = game.AddChannelGame(guildId, channelId, separateRanking)
+	if errors.Is(err, game.ErrChannelTaken) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("<#%d> já tem um jogo", channelId))
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not add game in channel %d of guild %d: %v\n", channelId, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	err = game.StartRound(channelId)
+	if err != nil {
+		log.Printf("starting first round in channel %d of guild %d: %v\n", channelId, guildId, err)
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Jogo criado em <#%d>, mas não consegui escolher um anúncio. Use **/anuncio** lá para tentar de novo", channelId))
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Jogo criado em <#%d>! Os comandos usados lá, como **/desligar_categoria** e **/dificuldade**, só valem para esse canal", channelId))
+}
+
+func removerCanal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	channelId, err := strconv.Atoi(i.ApplicationCommandData().Options[0].ChannelValue(s).ID)
+	if err != nil {
+		log.Printf("could not parse channel id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	thread := game.RoundThread(channelId)
+
+	err = game.RemoveChannelGame(guildId, channelId)
+	if errors.Is(err, game.ErrNoChannelGame) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("<#%d> não tem um jogo próprio. Use **/canais** para ver os canais com jogo", channelId))
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not remove game in channel %d of guild %d: %v\n", channelId, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go archiveThread(thread, "O jogo desse canal acabou")
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("O jogo de <#%d> acabou", channelId))
+}
+
+func canais(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	var response strings.Builder
+	if game.IsChannelSet(guildId) {
+		response.WriteString(fmt.Sprintf("<#%d> (canal principal)\n", game.InstanceChannel(guildId)))
+	}
+
+	for _, g := range game.ChannelGames(guildId) {
+		response.WriteString(fmt.Sprintf("<#%d>", g.ChannelId))
+		if g.SeparateRanking {
+			response.WriteString(" (ranking separado)")
+		}
+		response.WriteString("\n")
+	}
+
+	if response.Len() == 0 {
+		go RespondInteractionWithEmbed(i, "Esse servidor ainda não tem canais de jogo. Use **/canal** para configurar o canal principal")
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func tempo(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	minutes := i.ApplicationCommandData().Options[0].IntValue()
+
+	err = game.SetRoundTimeout(gameId, time.Duration(minutes)*time.Minute)
+	if err != nil {
+		log.Printf("could not set round timeout for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if minutes == 0 {
+		go RespondInteractionWithEmbed(i, "Feito! As rodadas não têm mais limite de tempo")
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! O preço será revelado depois de %d minutos de rodada", minutes))
+}
+
+func modo(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	mode := game.Mode(i.ApplicationCommandData().Options[0].StringValue())
+	if !slices.Contains(game.Modes, mode) {
+		log.Printf("setting mode %s which is not part of allowed modes %v\n", mode, game.Modes)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	maxGuesses := game.DefaultMaxGuesses
+	for _, opt := range i.ApplicationCommandData().Options[1:] {
+		if opt.Name == "chutes" {
+			maxGuesses = int(opt.IntValue())
+		}
+	}
+
+	err = game.SetMode(gameId, mode, maxGuesses)
+	if err != nil {
+		log.Printf("could not set game mode for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if mode == game.ModePriceIsRight {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Modo preço certo ligado! Cada pessoa tem %d chutes por rodada e, quando o tempo acabar, ganha quem chegar mais perto do preço sem passar", maxGuesses))
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, "Modo clássico ligado! Ganha quem acertar o preço primeiro")
+}
+
+func ranking(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "times" && opt.BoolValue() {
+			teamRanking(i)
+			return
+		}
+
+		if opt.Name == "ordem" && opt.StringValue() == "rating" {
+			ratingRanking(i, gameId)
+			return
+		}
+	}
+
+	scores, err := game.Ranking(gameId)
+	if err != nil {
+		log.Printf("fetching ranking for guild %s: %v\n", i.GuildID, err)
+		go RespondInteractionWithEmbed(i, ops)
+
+		return
+	}
+
+	if len(scores) == 0 {
+		go RespondInteractionWithEmbed(i, "Ninguém marcou pontos ainda")
+		return
+	}
+
+	var rankingString strings.Builder
+	for idx, s := range scores {
+		rankingString.WriteString(fmt.Sprintf("#%d %s(%d)\n", idx+1, s.Username, s.Score))
+	}
+
+	go RespondInteractionWithEmbed(i, rankingString.String())
+}
+
+// ratingRanking orders the players of a game by their rating instead of their points
+func ratingRanking(i *discordgo.InteractionCreate, gameId int) {
+	ratings, err := game.Ratings(gameId)
+	if err != nil {
+		log.Printf("fetching ratings for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if len(ratings) == 0 {
+		go RespondInteractionWithEmbed(i, "Ninguém tem rating ainda. Ele aparece depois da primeira rodada com mais de uma pessoa chutando")
+		return
+	}
+
+	var rankingString strings.Builder
+	for idx, r := range ratings {
+		rankingString.WriteString(fmt.Sprintf("#%d %s(%.0f, %d rodadas)\n", idx+1, r.Username, r.Rating, r.Rounds))
+	}
+
+	go RespondInteractionWithEmbed(i, rankingString.String())
+}
+
+func teamRanking(i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	scores, err := game.TeamRanking(guildId)
+	if err != nil {
+		log.Printf("fetching team ranking for guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if len(scores) == 0 {
+		go RespondInteractionWithEmbed(i, "Nenhum time marcou pontos ainda")
+		return
+	}
+
+	var rankingString strings.Builder
+	for idx, s := range scores {
+		rankingString.WriteString(fmt.Sprintf("#%d %s(%d)\n", idx+1, s.Team, s.Score))
+	}
+
+	go RespondInteractionWithEmbed(i, rankingString.String())
+}
+
+func jogarEmTimes(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	enabled := i.ApplicationCommandData().Options[0].BoolValue()
+
+	err = game.SetTeamsEnabled(guildId, enabled)
+	if err != nil {
+		log.Printf("could not set team mode for guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if enabled {
+		go RespondInteractionWithEmbed(i, "Modo em times ligado! Crie times com **/criar_time** e entre em um com **/entrar_time**")
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, "Modo em times desligado")
+}
+
+func criarTime(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	var name string
+	var roleId int
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "nome":
+			name = opt.StringValue()
+		case "cargo":
+			roleId, err = strconv.Atoi(opt.RoleValue(s, i.GuildID).ID)
+			if err != nil {
+				log.Printf("could not parse role id: %v\n", err)
+				go RespondInteractionWithEmbed(i, ops)
+				return
+			}
+		}
+	}
+
+	team, err := game.CreateTeam(guildId, name, roleId)
+	if errors.Is(err, game.ErrTeamExists) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Já existe um time chamado %s", name))
+		return
+	}
+
+	if errors.Is(err, game.ErrInvalidTeamName) {
+		go RespondInteractionWithEmbed(i, "Esse nome não pode ser usado para um time")
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not create team %s in guild %d: %v\n", name, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if team.RoleId != 0 {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Time %s criado! Quem tiver o cargo <@&%d> entra nele no primeiro chute", team.Name, team.RoleId))
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Time %s criado! Use **/entrar_time** para entrar nele", team.Name))
+}
+
+func times(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	teams, err := game.Teams(guildId)
+	if err != nil {
+		log.Printf("fetching teams of guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if len(teams) == 0 {
+		go RespondInteractionWithEmbed(i, "Esse servidor ainda não tem times")
+		return
+	}
+
+	var response strings.Builder
+	for _, t := range teams {
+		response.WriteString(t.Name)
+		if t.RoleId != 0 {
+			response.WriteString(fmt.Sprintf(" (<@&%d>)", t.RoleId))
+		}
+		response.WriteString("\n")
+	}
+
+	if !game.TeamsEnabled(guildId) {
+		response.WriteString("\nO modo em times está desligado, os pontos não estão contando para os times")
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func entrarTime(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	name := i.ApplicationCommandData().Options[0].StringValue()
+
+	team, err := game.JoinTeam(guildId, i.Member.User.Username, name)
+	if errors.Is(err, game.ErrNoTeam) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Não existe nenhum time chamado %s. Use **/times** para ver os times do servidor", name))
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not join team %s in guild %d: %v\n", name, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Você entrou no time %s!", team.Name))
+}
+
+func sairTime(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	err = game.LeaveTeam(guildId, i.Member.User.Username)
+	if err != nil {
+		log.Printf("could not leave team in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, "Você saiu do seu time")
+}
+
+func apostar(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if !game.IsChannelSet(guildId) {
+		go RespondInteractionWithEmbed(i, "Por favor, configure o canal do bot usando o comando **/canal**")
+		return
+	}
+
+	channelId, err := strconv.Atoi(i.ChannelID)
+	if err != nil {
+		log.Printf("could not parse channel id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	gameId, ok := game.GameIn(guildId, channelId)
+	if !ok {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("As apostas são feitas no canal do jogo, <#%d>", game.InstanceChannel(guildId)))
+		return
+	}
+
+	var guess, stake int
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "chute":
+			guess = int(opt.IntValue())
+		case "moedas":
+			stake = int(opt.IntValue())
+		}
+	}
+
+	userId, err := strconv.Atoi(i.Member.User.ID)
+	if err != nil {
+		log.Printf("could not parse user id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	username := i.Member.User.Username
+	if game.TeamsEnabled(guildId) {
+		joinTeamByRoles(guildId, username, i.Member.Roles)
+	}
+
+	isRight, err := game.PlaceBet(userId, username, guess, stake, gameId)
+	if errors.Is(err, game.ErrNotEnoughCoins) {
+		go RespondInteractionWithEmbed(i, "Você não tem moedas suficientes. Use **/carteira** para ver seu saldo e **/diaria** para pegar as moedas do dia")
+		return
+	}
+
+	if errors.Is(err, game.ErrAlreadyBet) {
+		go RespondInteractionWithEmbed(i, "Você já apostou nessa rodada")
+		return
+	}
+
+	if errors.Is(err, game.ErrGuessedBeforeBet) {
+		go RespondInteractionWithEmbed(i, "Você já chutou nessa rodada. Apostas só valem no primeiro chute")
+		return
+	}
+
+	if errors.Is(err, game.ErrRoundClosed) {
+		go RespondInteractionWithEmbed(i, "A rodada já acabou, espere a próxima")
+		return
+	}
+
+	if errors.Is(err, game.ErrNoGuessesLeft) {
+		go RespondInteractionWithEmbed(i, "Você não tem mais chutes nessa rodada")
+		return
+	}
+
+	if err != nil {
+		log.Printf("placing bet in game %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if isRight {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("%s apostou %d moedas em R$ %d e acertou em cheio!", username, stake, guess))
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("%s apostou %d moedas em R$ %d", username, stake, guess))
+}
+
+var ledgerReasons = map[game.LedgerReason]string{
+	game.LedgerBet:    "aposta",
+	game.LedgerPayout: "prêmio",
+	game.LedgerRefund: "aposta devolvida",
+	game.LedgerDaily:  "diária",
+}
+
+// how many ledger entries /carteira shows
+const ledgerEntriesShown = 10
+
+func carteira(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	username := i.Member.User.Username
+
+	coins, err := game.Balance(guildId, username)
+	if err != nil {
+		log.Printf("fetching balance of %s in guild %d: %v\n", username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	entries, err := game.Ledger(guildId, username, ledgerEntriesShown)
+	if err != nil {
+		log.Printf("fetching ledger of %s in guild %d: %v\n", username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("Você tem **%d moedas**", coins))
+	if len(entries) == 0 {
+		response.WriteString("\n\nUse **/diaria** para pegar suas primeiras moedas")
+	} else {
+		response.WriteString("\n\n**Últimas movimentações**")
+	}
+
+	for _, e := range entries {
+		response.WriteString(fmt.Sprintf("\n<t:%d:R> %+d (%s)", e.CreatedAt.Unix(), e.Amount, ledgerReasons[e.Reason]))
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func diaria(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	err = game.ClaimDaily(guildId, i.Member.User.Username)
+	if errors.Is(err, game.ErrAlreadyClaimed) {
+		go RespondInteractionWithEmbed(i, "Você já pegou suas moedas hoje, volte amanhã!")
+		return
+	}
+
+	if err != nil {
+		log.Printf("claiming daily allowance in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Você ganhou %d moedas! Use **/apostar** para apostar em um chute", game.DailyAllowance))
+}
+
+func desafio(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	username := i.Member.User.Username
+
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "ranking":
+			if opt.BoolValue() {
+				challengeLeaderboard(s, i)
+				return
+			}
+		case "chute":
+			challengeGuess(i, guildId, username, int(opt.IntValue()))
+			return
+		}
+	}
+
+	ad, err := game.Challenge()
+	if err != nil {
+		log.Printf("fetching daily challenge: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	left, err := game.ChallengeAttemptsLeft(username)
+	if err != nil {
+		log.Printf("fetching challenge attempts of %s: %v\n", username, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	embed := ChallengeEmbed(ad, left)
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{&embed},
+			},
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+		}
+	}()
+}
+
+// challengeGuess answers privately, so nobody learns the price from someone else's attempts
+func challengeGuess(i *discordgo.InteractionCreate, guildId int, username string, guess int) {
+	feedback, err := game.ChallengeGuess(guildId, username, guess)
+	if errors.Is(err, game.ErrChallengeSolved) {
+		go RespondInteractionPrivately(i, "Você já acertou o desafio de hoje! Veja como está o ranking com **/desafio ranking**")
+		return
+	}
+
+	if errors.Is(err, game.ErrNoAttemptsLeft) {
+		go RespondInteractionPrivately(i, "Suas tentativas de hoje acabaram. Tem desafio novo à meia-noite!")
+		return
+	}
+
+	if err != nil {
+		log.Printf("guessing daily challenge: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if feedback.Solved {
+		go RespondInteractionPrivately(i, fmt.Sprintf("Acertou! R$ %d foi perto o bastante. Veja sua posição com **/desafio ranking**", guess))
+		return
+	}
+
+	direction := "mais baixo"
+	if feedback.Higher {
+		direction = "mais alto"
+	}
+
+	if feedback.AttemptsLeft == 0 {
+		go RespondInteractionPrivately(i, fmt.Sprintf("O preço é %s que R$ %d. Suas tentativas de hoje acabaram!", direction, guess))
+		return
+	}
+
+	go RespondInteractionPrivately(i, fmt.Sprintf("O preço é %s que R$ %d. Você ainda tem %d tentativas", direction, guess, feedback.AttemptsLeft))
+}
+
+// how many users the challenge leaderboard shows
+const challengeStandingsShown = 15
+
+func challengeLeaderboard(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	standings, err := game.ChallengeLeaderboard()
+	if err != nil {
+		log.Printf("fetching challenge leaderboard: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if len(standings) == 0 {
+		go RespondInteractionWithEmbed(i, "Ninguém jogou o desafio de hoje ainda. Seja o primeiro com **/desafio**!")
+		return
+	}
+
+	var response strings.Builder
+	response.WriteString("**Ranking do desafio do dia**\n")
+	for idx, st := range standings[:min(len(standings), challengeStandingsShown)] {
+		guild := "outro servidor"
+		if g, err := s.State.Guild(strconv.Itoa(st.GuildId)); err == nil {
+			guild = g.Name
+		}
+
+		if st.Solved {
+			response.WriteString(fmt.Sprintf("\n#%d %s (%s) acertou em %d tentativas", idx+1, st.Username, guild, st.Attempts))
+		} else {
+			response.WriteString(fmt.Sprintf("\n#%d %s (%s) ainda não acertou", idx+1, st.Username, guild))
+		}
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func duelo(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	channelId, err := strconv.Atoi(i.ChannelID)
+	if err != nil {
+		log.Printf("could not parse channel id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	opponent := i.ApplicationCommandData().Options[0].UserValue(s)
+	if opponent.Bot {
+		go RespondInteractionWithEmbed(i, "Bots não duelam")
+		return
+	}
+
+	challenger := i.Member.User.Username
+	_, err = game.StartDuel(guildId, channelId, challenger, opponent.Username)
+	if errors.Is(err, game.ErrSelfDuel) {
+		go RespondInteractionWithEmbed(i, "Você não pode duelar com você mesmo")
+		return
+	}
+
+	if errors.Is(err, game.ErrInDuel) {
+		go RespondInteractionWithEmbed(i, "Alguém aqui já está em um duelo. Espere ele acabar")
+		return
+	}
+
+	if err != nil {
+		log.Printf("starting duel in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	expiresAt := time.Now().Add(game.DuelAcceptTimeout).Unix()
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{{
+					Description: fmt.Sprintf("⚔️ %s desafiou <@%s> para um duelo! O desafio expira <t:%d:R>", challenger, opponent.ID, expiresAt),
+				}},
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{
+						Components: []discordgo.MessageComponent{
+							discordgo.Button{
+								Label:    "Aceitar",
+								Style:    discordgo.PrimaryButton,
+								CustomID: "duelo:aceitar",
+							},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+		}
+	}()
+}
+
+// aceitarDuelo is a click on the button of a duel challenge. Only the
+// opponent can accept it, and they get to see the ad right away
+func aceitarDuelo(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	duel, err := game.AcceptDuel(guildId, i.Member.User.Username)
+	if errors.Is(err, game.ErrNoDuelChallenge) {
+		go RespondInteractionPrivately(i, "Esse desafio não é para você ou já expirou")
+		return
+	}
+
+	if err != nil {
+		log.Printf("accepting duel in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds: []*discordgo.MessageEmbed{{
+					Description: fmt.Sprintf(
+						"⚔️ %s aceitou o duelo contra %s! Cada um tem %d chutes e %d minutos. Usem **/duelo_anuncio** para ver o anúncio e **/duelo_chute** para chutar, só vocês vão ver",
+						duel.Opponent, duel.Challenger, game.DuelGuesses, int(game.DuelTimeout/time.Minute)),
+				}},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+			return
+		}
+
+		embed := AdEmbed(duel.Ad)
+		_, err = s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+			Flags:  discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{&embed},
+		})
+		if err != nil {
+			log.Printf("sending duel ad: %v\n", err)
+		}
+	}()
+}
+
+func dueloAnuncio(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	duel, err := game.CurrentDuel(guildId, i.Member.User.Username)
+	if errors.Is(err, game.ErrNoDuel) {
+		go RespondInteractionPrivately(i, "Você não está em um duelo. Desafie alguém com **/duelo**")
+		return
+	}
+
+	if errors.Is(err, game.ErrDuelNotAccepted) {
+		go RespondInteractionPrivately(i, "Seu duelo ainda não foi aceito")
+		return
+	}
+
+	if err != nil {
+		log.Printf("fetching duel in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	embed := AdEmbed(duel.Ad)
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Duelo contra %s", duelRival(duel, i.Member.User.Username)),
+	}
+	embed.Timestamp = duel.StartedAt.Add(game.DuelTimeout).Format(time.RFC3339)
+
+	go func() {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Flags:  discordgo.MessageFlagsEphemeral,
+				Embeds: []*discordgo.MessageEmbed{&embed},
+			},
+		})
+		if err != nil {
+			log.Printf("could not respond to interaction: %v\n", err)
+		}
+	}()
 }
 
-func anuncio(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	guildId, err := strconv.Atoi(i.GuildID)
-	if err != nil {
-		log.Printf("could not parse guild id: %v\n", err)
-		RespondInteractionWithEmbed(i, ops)
+func duelRival(duel game.Duel, username string) string {
+	if duel.Challenger == username {
+		return duel.Opponent
+	}
+
+	return duel.Challenger
+}
+
+func dueloChute(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
+	if err != nil {
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	guess := int(i.ApplicationCommandData().Options[0].IntValue())
+
+	feedback, err := game.DuelGuess(guildId, i.Member.User.Username, guess)
+	if errors.Is(err, game.ErrNoDuel) {
+		go RespondInteractionPrivately(i, "Você não está em um duelo. Desafie alguém com **/duelo**")
+		return
+	}
+
+	if errors.Is(err, game.ErrDuelNotAccepted) {
+		go RespondInteractionPrivately(i, "Seu duelo ainda não foi aceito")
+		return
+	}
+
+	if errors.Is(err, game.ErrNoDuelGuessesLeft) {
+		go RespondInteractionPrivately(i, "Seus chutes acabaram, agora é esperar o resultado")
 		return
 	}
 
-	if !game.IsChannelSet(guildId) {
-		RespondInteractionWithEmbed(i, "Por favor, configure o canal do bot usando o comando **/canal**")
+	if err != nil {
+		log.Printf("guessing in duel in guild %d: %v\n", guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	if !game.HasAd(guildId) {
-		err = game.NewRound(guildId)
-		if err != nil {
-			log.Println(err)
-			RespondInteractionWithEmbed(i, ops)
-			return
-		}
+	var response string
+	switch {
+	case feedback.Exact:
+		response = fmt.Sprintf("R$ %d é o preço certinho!", guess)
+	case feedback.Close:
+		response = fmt.Sprintf("R$ %d passou perto!", guess)
+	default:
+		response = fmt.Sprintf("Chute de R$ %d registrado", guess)
 	}
 
-	go RespondInteractionWithAd(s, i, game.Ad(guildId))
+	if feedback.GuessesLeft > 0 {
+		response += fmt.Sprintf(". Você ainda tem %d chutes", feedback.GuessesLeft)
+	}
+
+	go RespondInteractionPrivately(i, response)
 }
 
-func pular(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func perfil(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	guildId, err := strconv.Atoi(i.GuildID)
 	if err != nil {
-		panic("could not register command handlers")
+		log.Printf("could not parse guild id: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
 	}
 
-	if !game.IsChannelSet(guildId) {
-		go RespondInteractionWithEmbed(i, "Por favor, configure o canal do bot usando o comando **/canal**")
+	username := i.Member.User.Username
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "usuario" {
+			username = opt.UserValue(s).Username
+		}
+	}
+
+	record, err := game.DuelRecordOf(guildId, username)
+	if err != nil {
+		log.Printf("fetching duel record of %s in guild %d: %v\n", username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	err = game.NewRound(guildId)
+	coins, err := game.Balance(guildId, username)
 	if err != nil {
-		log.Println(err)
-		go RespondInteractionWithEmbed(i, "Não consegui escolher um anuncio novo :(")
+		log.Printf("fetching balance of %s in guild %d: %v\n", username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	RespondInteractionWithEmbed(i, "Começando nova rodada!")
-	SendAdInChannel(i.ChannelID, i.GuildID, game.Ad(guildId))
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**%s**\n", username))
+	response.WriteString(fmt.Sprintf("\nDuelos: %d vitórias, %d derrotas e %d empates", record.Wins, record.Losses, record.Draws))
+	response.WriteString(fmt.Sprintf("\nMoedas: %d", coins))
+
+	if team, err := game.TeamOf(guildId, username); err == nil {
+		response.WriteString(fmt.Sprintf("\nTime: %s", team.Name))
+	} else if !errors.Is(err, game.ErrNoTeam) {
+		log.Printf("fetching team of %s in guild %d: %v\n", username, guildId, err)
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
 
-	game.OpenRound(guildId)
+var guessStyles = map[game.GuessStyle]string{
+	game.GuessStyleThousands: "milhares redondos (3000)",
+	game.GuessStyleHundreds:  "centenas redondas (3200)",
+	game.GuessStyleCharm:     "preço de loja (2999)",
+	game.GuessStylePrecise:   "valores quebrados (3150)",
 }
 
-func canal(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func stats(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	guildId, err := strconv.Atoi(i.GuildID)
 	if err != nil {
 		log.Printf("could not parse guild id: %v\n", err)
 		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
-	options := i.ApplicationCommandData().Options
 
-	_, err = db.Conn.Exec(`
-		UPDATE guilds
-		SET game_channel_id = ?
-		WHERE discord_id = ?
-	`, options[0].Value, guildId)
+	user := i.Member.User
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "usuario" {
+			user = opt.UserValue(s)
+		}
+	}
+
+	userId, err := strconv.Atoi(user.ID)
 	if err != nil {
-		log.Printf("could not set channel for guild %d: %v\n", guildId, err)
+		log.Printf("could not parse user id: %v\n", err)
 		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	channelId, err := strconv.Atoi(options[0].ChannelValue(s).ID)
+	st, err := game.StatsOf(guildId, userId, user.Username)
 	if err != nil {
-		log.Println(err)
+		log.Printf("fetching stats of %s in guild %d: %v\n", user.Username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	game.SetChannel(guildId, channelId)
-	go RespondInteractionWithEmbed(i, "Canal do bot configurado!")
-}
+	if st.Guesses == 0 {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("%s ainda não chutou em nenhuma rodada", user.Username))
+		return
+	}
 
-func ranking(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	var scores []AggregatedScore
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**Estatísticas de %s**\n", user.Username))
+	response.WriteString(fmt.Sprintf("\nChutes: %d", st.Guesses))
+	response.WriteString(fmt.Sprintf("\nVitórias: %d", st.Wins))
+	response.WriteString(fmt.Sprintf("\nErro médio: %.1f%%", st.AverageError))
+	if st.Wins > 0 {
+		response.WriteString(fmt.Sprintf("\nChutes para ganhar (mediana): %g", st.MedianGuessesToWin))
+	}
+
+	if st.BestCategory != "" {
+		response.WriteString(fmt.Sprintf("\nMelhor categoria: %s", st.BestCategory))
+	}
 
-	rows, err := db.Conn.Query(`
-		SELECT username, COUNT(*) as score
-		FROM scores
-		WHERE guild_id = ?
-		GROUP BY username
-		ORDER BY COUNT(*) DESC`, i.GuildID)
+	response.WriteString(fmt.Sprintf("\nEstilo de chute favorito: %s", guessStyles[st.FavoriteStyle]))
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func conquistas(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	guildId, err := strconv.Atoi(i.GuildID)
 	if err != nil {
-		log.Printf("fetching ranking for guild %s: %v\n", i.GuildID, err)
+		log.Printf("could not parse guild id: %v\n", err)
 		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	username := i.Member.User.Username
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "usuario" {
+			username = opt.UserValue(s).Username
+		}
+	}
 
+	unlocked, err := game.BadgesOf(guildId, username)
+	if err != nil {
+		log.Printf("fetching badges of %s in guild %d: %v\n", username, guildId, err)
+		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
 
-	defer rows.Close()
+	unlockedAt := make(map[game.Badge]time.Time)
+	for _, b := range unlocked {
+		unlockedAt[b.Badge] = b.UnlockedAt
+	}
 
-	for rows.Next() {
-		sc := AggregatedScore{}
-		err = rows.Scan(&sc.Username, &sc.Score)
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("**Conquistas de %s** (%d de %d)\n", username, len(unlocked), len(game.Badges)))
+	for _, badge := range game.Badges {
+		info := badges[badge]
+		if at, ok := unlockedAt[badge]; ok {
+			response.WriteString(fmt.Sprintf("\n%s **%s**: %s (<t:%d:d>)", info.Emoji, info.Name, info.Description, at.Unix()))
+		} else {
+			response.WriteString(fmt.Sprintf("\n🔒 **%s**: %s", info.Name, info.Description))
+		}
+	}
 
-		if err != nil {
-			log.Printf("fetching ranking for guild %s: %v\n", i.GuildID, err)
-			go RespondInteractionWithEmbed(i, ops)
+	go RespondInteractionWithEmbed(i, response.String())
+}
 
-			return
+func historico(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	page := 1
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "pagina" {
+			page = int(opt.IntValue())
 		}
+	}
 
-		scores = append(scores, sc)
+	pages, err := game.HistoryLen(gameId)
+	if err != nil {
+		log.Printf("counting finished rounds for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
 	}
 
-	if len(scores) == 0 {
-		go RespondInteractionWithEmbed(i, "Ninguém marcou pontos ainda")
+	if pages == 0 {
+		go RespondInteractionWithEmbed(i, "Nenhuma rodada terminou ainda")
 		return
 	}
 
-	var rankingString strings.Builder
-	for idx, s := range scores {
-		rankingString.WriteString(fmt.Sprintf("#%d %s(%d)\n", idx+1, s.Username, s.Score))
+	if page > pages {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("O histórico só tem %d páginas", pages))
+		return
 	}
 
-	go RespondInteractionWithEmbed(i, rankingString.String())
+	round, err := game.History(gameId, page-1)
+	if err != nil {
+		log.Printf("fetching round history for guild %d: %v\n", gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	embed := HistoryEmbed(round, page, pages)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				&embed,
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("could not respond to interaction: %v\n", err)
+	}
 }
 
 func categorias(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	rows, err := db.Conn.Query(`
-		SELECT category
-		FROM disabled_categories
-		WHERE guild_id = ?`, i.GuildID)
+	gameId, err := interactionGame(i)
 	if err != nil {
-		log.Printf("fetching disabled categories for guild %s: %v\n", i.GuildID, err)
+		log.Printf("could not find the game of the interaction: %v\n", err)
 		go RespondInteractionWithEmbed(i, ops)
 		return
 	}
-	defer rows.Close()
-
-	var disabledCategories []string
-
-	for rows.Next() {
-		var c string
-		err = rows.Scan(&c)
-
-		if err != nil {
-			log.Printf("fetching disabled categories for guild %s: %v\n", i.GuildID, err)
-			go RespondInteractionWithEmbed(i, ops)
-			return
-		}
 
-		disabledCategories = append(disabledCategories, c)
+	disabledCategories, err := game.DisabledCategories(gameId)
+	if err != nil {
+		log.Printf("fetching disabled categories for guild %s: %v\n", i.GuildID, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
 	}
 
 	enabledCategories := removeItems(olx.Categories, disabledCategories)
@@ -194,6 +1438,13 @@ func categorias(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 func ligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
 	cmdOpts := i.ApplicationCommandData().Options
 	if cmdOpts == nil {
 		log.Printf("enabling category in guild %s: command data is nil\n", i.GuildID)
@@ -209,9 +1460,7 @@ func ligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
-	_, err := db.Conn.Exec(`
-		DELETE FROM disabled_categories
-		WHERE guild_id = ? AND category = ?`, i.GuildID, category)
+	err = game.SetCategoryEnabled(gameId, category, true)
 
 	if err != nil {
 		log.Printf("deleting category %s from disabled_categories in guild %s: %v\n", category, i.GuildID, err)
@@ -223,6 +1472,13 @@ func ligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
 }
 
 func desligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
 	cmdOpts := i.ApplicationCommandData().Options
 	if cmdOpts == nil {
 		log.Printf("disabling category in guild %s: command data is nil\n", i.GuildID)
@@ -238,9 +1494,7 @@ func desligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
-	_, err := db.Conn.Exec(`
-				INSERT OR IGNORE INTO disabled_categories (guild_id, category)
-				VALUES (?, ?)`, i.GuildID, category)
+	err = game.SetCategoryEnabled(gameId, category, false)
 
 	if err != nil {
 		log.Printf("inserting guild_id %s and category %s into disabled_categories: %v\n", i.GuildID, category, err)
@@ -251,8 +1505,207 @@ func desligarCategoria(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! Anúncios de %s não aparecerão mais nas próximas rodadas", category))
 }
 
+// priceRangeDescription explains which ads a price range allows, as in "de R$ 10 até R$ 2000"
+func priceRangeDescription(prices game.PriceRange) string {
+	if prices.Max == 0 {
+		if prices.Min == 0 {
+			return "de qualquer preço"
+		}
+
+		return fmt.Sprintf("a partir de R$ %d", prices.Min)
+	}
+
+	return fmt.Sprintf("de R$ %d até R$ %d", prices.Min, prices.Max)
+}
+
+func dificuldade(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	difficulty := game.Difficulty(i.ApplicationCommandData().Options[0].StringValue())
+
+	var prices game.PriceRange
+	if difficulty == game.DifficultyCustom {
+		for _, opt := range i.ApplicationCommandData().Options[1:] {
+			switch opt.Name {
+			case "minimo":
+				prices.Min = int(opt.IntValue())
+			case "maximo":
+				prices.Max = int(opt.IntValue())
+			}
+		}
+
+		err = game.SetPriceRange(gameId, prices)
+	} else {
+		err = game.SetDifficulty(gameId, difficulty)
+	}
+
+	if errors.Is(err, game.ErrInvalidPriceRange) {
+		go RespondInteractionWithEmbed(i, "O preço máximo precisa ser maior que o mínimo")
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not set difficulty %s for guild %d: %v\n", difficulty, gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	_, prices = game.GuildDifficulty(gameId)
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! A partir da próxima rodada, os anúncios serão %s", priceRangeDescription(prices)))
+}
+
+// toleranceDescription explains how close guesses need to be, as in "por até R$ 100"
+func toleranceDescription(t game.Tolerance) string {
+	switch t.Kind {
+	case game.ToleranceReais:
+		return fmt.Sprintf("por até R$ %d", t.Amount)
+	case game.TolerancePercent:
+		return fmt.Sprintf("por até %d%% do preço", t.Amount)
+	}
+
+	return "só o preço exato"
+}
+
+func tolerancia(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	tolerance := game.Tolerance{Kind: game.ToleranceKind(i.ApplicationCommandData().Options[0].StringValue())}
+	if tolerance.Kind != game.ToleranceExact {
+		for _, opt := range i.ApplicationCommandData().Options[1:] {
+			if opt.Name == "valor" {
+				tolerance.Amount = int(opt.IntValue())
+			}
+		}
+	}
+
+	err = game.SetTolerance(gameId, tolerance)
+	if errors.Is(err, game.ErrInvalidTolerance) {
+		go RespondInteractionWithEmbed(i, fmt.Sprintf("Escolha um valor maior que zero, até R$ %d na tolerância em reais e até %d%% na tolerância em porcentagem", game.MaxToleranceReais, game.MaxTolerancePercent))
+		return
+	}
+
+	if err != nil {
+		log.Printf("could not set win tolerance %v for guild %d: %v\n", tolerance, gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if tolerance.Kind == game.ToleranceExact {
+		go RespondInteractionWithEmbed(i, "Feito! Agora só ganha quem acertar o preço exato")
+		return
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! Agora ganha quem errar o preço %s", toleranceDescription(tolerance)))
+}
+
+// hintRuleDescription explains when a hint is given, as in "depois de 10 chutes, a cada 10 chutes"
+func hintRuleDescription(rule game.HintRule) string {
+	if !rule.Enabled() {
+		return "desligada"
+	}
+
+	var description string
+	if rule.Elapsed > 0 {
+		description = fmt.Sprintf("depois de %d minutos de rodada", int(rule.Elapsed/time.Minute))
+	} else {
+		description = fmt.Sprintf("depois de %d chutes", rule.Guesses)
+		if rule.Every == 1 {
+			description += ", a cada chute"
+		} else if rule.Every > 1 {
+			description += fmt.Sprintf(", a cada %d chutes", rule.Every)
+		}
+	}
+
+	if rule.Chance < 100 {
+		description += fmt.Sprintf(" (%d%% de chance)", rule.Chance)
+	}
+
+	return description
+}
+
+func dicas(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	if game.GameMode(gameId) == game.ModePriceIsRight {
+		go RespondInteractionWithEmbed(i, "No modo preço certo não tem dicas")
+		return
+	}
+
+	var response strings.Builder
+	for _, rule := range game.HintSchedule(gameId) {
+		response.WriteString(fmt.Sprintf("**%s**: %s\n", hintNames[rule.Kind], hintRuleDescription(rule)))
+	}
+
+	go RespondInteractionWithEmbed(i, response.String())
+}
+
+func dica(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	gameId, err := interactionGame(i)
+	if err != nil {
+		log.Printf("could not find the game of the interaction: %v\n", err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	rule := game.HintRule{
+		Kind:   i.ApplicationCommandData().Options[0].StringValue(),
+		Chance: 100,
+	}
+
+	reset := false
+	for _, opt := range i.ApplicationCommandData().Options[1:] {
+		switch opt.Name {
+		case "chutes":
+			rule.Guesses = int(opt.IntValue())
+		case "a_cada":
+			rule.Every = int(opt.IntValue())
+		case "minutos":
+			rule.Elapsed = time.Duration(opt.IntValue()) * time.Minute
+		case "chance":
+			rule.Chance = int(opt.IntValue())
+		case "padrao":
+			reset = opt.BoolValue()
+		}
+	}
+
+	if reset {
+		err = game.ResetHintRule(gameId, rule.Kind)
+	} else {
+		err = game.SetHintRule(gameId, rule)
+	}
+
+	if err != nil {
+		log.Printf("could not set hint rule %+v for guild %d: %v\n", rule, gameId, err)
+		go RespondInteractionWithEmbed(i, ops)
+		return
+	}
+
+	for _, r := range game.HintSchedule(gameId) {
+		if r.Kind == rule.Kind {
+			rule = r
+		}
+	}
+
+	go RespondInteractionWithEmbed(i, fmt.Sprintf("Feito! **%s**: %s", hintNames[rule.Kind], hintRuleDescription(rule)))
+}
+
 func ajuda(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	RespondInteractionWithEmbed(i, "Tente adivinhar o preço de anúncios da OLX! Use o comando /canal para configurar o canal do bot. Ele só enviará mensagens nesse canal e só lerá as mensagens de lá. Use /anuncio para ver a rodada atual. Se o bot reagir a sua mensagem com um 🥶, significa que seu chute foi frio. Ele também avisará quando o chute passar perto, mas se não tiver nem perto nem frio nada vai acontecer. Não tenha medo de spammar! Quantos mais chutes errados, mais dicas ele dará. Para ver todos os comandos, use /comandos")
+	RespondInteractionWithEmbed(i, "Tente adivinhar o preço de anúncios da OLX! Quem acerta o preço em cheio ganha 10 pontos, e os chutes mais próximos de cada rodada também pontuam. Use o comando /canal para configurar o canal do bot. Ele só enviará mensagens nesse canal e só lerá as mensagens de lá. Use /anuncio para ver a rodada atual. Se o bot reagir a sua mensagem com um 🥶, significa que seu chute foi frio. Ele também avisará quando o chute passar perto, mas se não tiver nem perto nem frio nada vai acontecer. Não tenha medo de spammar! Quantos mais chutes errados, mais dicas ele dará. Para ver todos os comandos, use /comandos")
 }
 
 func comandos(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -267,13 +1720,94 @@ func comandos(s *discordgo.Session, i *discordgo.InteractionCreate) {
 Mostra o anuncio atual
 
 **/pular**
-Pula o anuncio atual
+Abre uma votação para pular o anuncio atual (admins pulam direto)
+
+**/votos_para_pular**
+Escolhe quantos votos são precisos para pular uma rodada
 
 **/canal**
 Configura em qual canal o bot vai funcionar
 
+**/topicos**
+Liga ou desliga um tópico para cada rodada, onde os chutes são feitos
+
+**/novo_canal**
+Cria um jogo separado em outro canal, com sua própria rodada e configurações
+
+**/remover_canal**
+Acaba com o jogo de um canal criado com /novo_canal
+
+**/canais**
+Lista os canais com jogo no servidor
+
+**/tempo**
+Configura quantos minutos cada rodada dura antes do preço ser revelado
+
+**/modo**
+Escolhe entre o modo clássico e o modo preço certo
+
 **/ranking**
-Veja onde você está no ranking desse servidor
+Veja onde você está no ranking desse servidor, por pontos ou por rating, ou o ranking dos times
+
+**/jogar_em_times**
+Liga ou desliga o modo em times
+
+**/criar_time**
+Cria um time, que pode estar ligado a um cargo do servidor
+
+**/times**
+Lista os times do servidor
+
+**/entrar_time**
+Entra em um time
+
+**/sair_time**
+Sai do seu time
+
+**/apostar**
+Aposta moedas em um chute. Quanto mais perto do preço, mais moedas você ganha
+
+**/carteira**
+Mostra suas moedas e as últimas movimentações
+
+**/diaria**
+Pega suas moedas do dia
+
+**/desafio**
+O desafio do dia, com o mesmo anúncio para todos os servidores
+
+**/duelo**
+Desafia alguém para um duelo, que começa quando a pessoa aceitar: quem chegar mais perto do preço ganha
+
+**/duelo_anuncio**
+Mostra o anúncio do seu duelo, só para você
+
+**/duelo_chute**
+Chuta o preço do anúncio do seu duelo
+
+**/perfil**
+Mostra os duelos, moedas e time de alguém
+
+**/stats**
+Mostra as estatísticas de alguém: chutes, vitórias, erro médio e mais
+
+**/conquistas**
+Mostra as conquistas que alguém desbloqueou
+
+**/historico**
+Mostra as rodadas que já terminaram nesse servidor
+
+**/dificuldade**
+Escolhe a faixa de preço dos anúncios das rodadas
+
+**/tolerancia**
+Escolhe o quão perto do preço um chute precisa chegar para ganhar a rodada
+
+**/dicas**
+Mostra quando cada dica é dada nesse servidor
+
+**/dica**
+Configura depois de quantos chutes ou minutos uma dica é dada
 
 **/ajuda**
 O que esse bot faz?
@@ -299,11 +1833,44 @@ Os comandos desse bot
 var Handlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
 	"anuncio":            anuncio,
 	"pular":              pular,
+	"votos_para_pular":   votosParaPular,
 	"canal":              canal,
+	"topicos":            topicos,
+	"novo_canal":         novoCanal,
+	"remover_canal":      removerCanal,
+	"canais":             canais,
+	"tempo":              tempo,
+	"modo":               modo,
 	"ranking":            ranking,
+	"jogar_em_times":     jogarEmTimes,
+	"criar_time":         criarTime,
+	"times":              times,
+	"entrar_time":        entrarTime,
+	"sair_time":          sairTime,
+	"dificuldade":        dificuldade,
+	"tolerancia":         tolerancia,
+	"dicas":              dicas,
+	"dica":               dica,
+	"apostar":            apostar,
+	"carteira":           carteira,
+	"diaria":             diaria,
+	"desafio":            desafio,
+	"duelo":              duelo,
+	"duelo_anuncio":      dueloAnuncio,
+	"duelo_chute":        dueloChute,
+	"perfil":             perfil,
+	"conquistas":         conquistas,
+	"stats":              stats,
+	"historico":          historico,
 	"categorias":         categorias,
 	"ligar_categoria":    ligarCategoria,
 	"desligar_categoria": desligarCategoria,
 	"ajuda":              ajuda,
 	"comandos":           comandos,
 }
+
+// ComponentHandlers handle clicks on buttons by the part of their custom id before the colon
+var ComponentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+	"pular": votarPular,
+	"duelo": aceitarDuelo,
+}
@@ -4,11 +4,49 @@ import (
 	"slices"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/gabrieleiro/olx-bets/bot/game"
 	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
 
 var ops = "Ops! Algo deu errado"
 
+var minRoundTimeout = 0.0
+var minGuessesPerRound = 1.0
+var minHistoryPage = 1.0
+var minHintGuesses = 0.0
+var minHintChance = 1.0
+var minAdPrice = 0.0
+var minStake = 1.0
+var minTolerance = 1.0
+var minSkipVotes = 1.0
+
+// commands that change how the game works are only for people who can manage the server
+var adminPermission int64 = discordgo.PermissionManageServer
+
+var hintNames = map[string]string{
+	game.HintZeros:      "Quantidade de zeros no preço",
+	game.HintSamePrice:  "Anúncio com o mesmo preço",
+	game.HintClosest:    "Chute mais próximo até agora",
+	game.HintDigits:     "Quantidade de dígitos do preço",
+	game.HintFirstDigit: "Primeiro dígito do preço",
+	game.HintBracket:    "Faixa de preço",
+	game.HintCategory:   "Categoria do anúncio",
+	game.HintMasked:     "Preço com alguns dígitos escondidos",
+}
+
+func hintChoices() []*discordgo.ApplicationCommandOptionChoice {
+	var res []*discordgo.ApplicationCommandOptionChoice
+
+	for _, kind := range game.HintKinds {
+		res = append(res, &discordgo.ApplicationCommandOptionChoice{
+			Name:  hintNames[kind],
+			Value: kind,
+		})
+	}
+
+	return res
+}
+
 func removeItems(a []string, b []string) []string {
 	var res []string
 
@@ -41,7 +79,22 @@ var Commands = []*discordgo.ApplicationCommand{
 	},
 	{
 		Name:        "pular",
-		Description: "Pula a rodada e sorteia um novo anuncio",
+		Description: "Abre uma votação para pular a rodada e sortear um novo anuncio",
+	},
+	{
+		Name:                     "votos_para_pular",
+		Description:              "Escolhe quantos votos são precisos para pular uma rodada",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "votos",
+				Description: "Quantos votos pulam a rodada",
+				MinValue:    &minSkipVotes,
+				MaxValue:    game.MaxSkipVotes,
+				Required:    true,
+			},
+		},
 	},
 	{
 		Name:        "ajuda",
@@ -63,6 +116,220 @@ var Commands = []*discordgo.ApplicationCommand{
 			},
 		},
 	},
+	{
+		Name:                     "topicos",
+		Description:              "Liga ou desliga um tópico para cada rodada, onde os chutes são feitos",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "ligado",
+				Description: "Se cada rodada ganha um tópico",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:                     "novo_canal",
+		Description:              "Cria um jogo separado em outro canal, com sua própria rodada e configurações",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionChannel,
+				Name:        "canal",
+				Description: "Canal",
+				ChannelTypes: []discordgo.ChannelType{
+					discordgo.ChannelTypeGuildText,
+				},
+				Required: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "ranking_separado",
+				Description: "Os pontos desse canal vão para um ranking só dele em vez do ranking do servidor",
+			},
+		},
+	},
+	{
+		Name:                     "remover_canal",
+		Description:              "Acaba com o jogo de um canal criado com /novo_canal",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionChannel,
+				Name:        "canal",
+				Description: "Canal",
+				ChannelTypes: []discordgo.ChannelType{
+					discordgo.ChannelTypeGuildText,
+				},
+				Required: true,
+			},
+		},
+	},
+	{
+		Name:        "canais",
+		Description: "Lista os canais com jogo no servidor",
+	},
+	{
+		Name:                     "tempo",
+		Description:              "Configura quantos minutos cada rodada dura antes do preço ser revelado",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "minutos",
+				Description: "Duração da rodada em minutos (0 para rodadas sem limite)",
+				MinValue:    &minRoundTimeout,
+				MaxValue:    60 * 24,
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:                     "modo",
+		Description:              "Escolhe o modo de jogo do servidor",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "modo",
+				Description: "Modo de jogo",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Clássico: ganha quem acertar o preço", Value: string(game.ModeClassic)},
+					{Name: "Preço certo: ganha quem chegar mais perto sem passar", Value: string(game.ModePriceIsRight)},
+				},
+				Required: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "chutes",
+				Description: "Quantos chutes cada pessoa tem por rodada no modo preço certo",
+				MinValue:    &minGuessesPerRound,
+				MaxValue:    20,
+			},
+		},
+	},
+	{
+		Name:        "historico",
+		Description: "Mostra as rodadas que já terminaram nesse servidor",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "pagina",
+				Description: "Página do histórico, começando pela rodada mais recente",
+				MinValue:    &minHistoryPage,
+			},
+		},
+	},
+	{
+		Name:                     "dificuldade",
+		Description:              "Escolhe a faixa de preço dos anúncios das rodadas",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "nivel",
+				Description: "Nível de dificuldade",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Fácil: anúncios de R$ 10 até R$ 2.000", Value: string(game.DifficultyEasy)},
+					{Name: "Médio: anúncios de R$ 10 até R$ 20.000", Value: string(game.DifficultyMedium)},
+					{Name: "Difícil: qualquer anúncio", Value: string(game.DifficultyHard)},
+					{Name: "Personalizado: escolha o preço mínimo e máximo", Value: string(game.DifficultyCustom)},
+				},
+				Required: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "minimo",
+				Description: "Preço mínimo dos anúncios no nível personalizado",
+				MinValue:    &minAdPrice,
+				MaxValue:    olx.OLX_MAX_PRICE,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "maximo",
+				Description: "Preço máximo dos anúncios no nível personalizado (0 para não ter limite)",
+				MinValue:    &minAdPrice,
+				MaxValue:    olx.OLX_MAX_PRICE,
+			},
+		},
+	},
+	{
+		Name:                     "tolerancia",
+		Description:              "Escolhe o quão perto do preço um chute precisa chegar para ganhar a rodada",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "tipo",
+				Description: "Tipo de tolerância",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Exato: só o preço certinho ganha", Value: string(game.ToleranceExact)},
+					{Name: "Reais: ganha quem errar por até tantos reais", Value: string(game.ToleranceReais)},
+					{Name: "Porcentagem: ganha quem errar por até tantos % do preço", Value: string(game.TolerancePercent)},
+				},
+				Required: true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "valor",
+				Description: "Quantos reais ou quantos % de erro são aceitos",
+				MinValue:    &minTolerance,
+				MaxValue:    game.MaxToleranceReais,
+			},
+		},
+	},
+	{
+		Name:        "dicas",
+		Description: "Mostra quando cada dica é dada nesse servidor",
+	},
+	{
+		Name:                     "dica",
+		Description:              "Configura quando uma dica é dada",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "tipo",
+				Description: "Tipo de dica",
+				Choices:     hintChoices(),
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "chutes",
+				Description: "Depois de quantos chutes a dica é dada (0 para desligar)",
+				MinValue:    &minHintGuesses,
+				MaxValue:    1000,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "a_cada",
+				Description: "Repete a dica a cada tantos chutes",
+				MinValue:    &minHintGuesses,
+				MaxValue:    1000,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "minutos",
+				Description: "Dá a dica depois de tantos minutos de rodada, em vez de contar os chutes",
+				MinValue:    &minHintGuesses,
+				MaxValue:    60 * 24,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "chance",
+				Description: "Chance em porcentagem da dica ser dada cada vez (padrão 100)",
+				MinValue:    &minHintChance,
+				MaxValue:    100,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "padrao",
+				Description: "Volta essa dica para a configuração padrão",
+			},
+		},
+	},
 	{
 		Name:        "comandos",
 		Description: "Lista os comandos disponíveis",
@@ -70,6 +337,184 @@ var Commands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "ranking",
 		Description: "Veja onde você está no ranking desse servidor.",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "times",
+				Description: "Mostra o ranking dos times em vez do ranking de cada pessoa",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "ordem",
+				Description: "Como ordenar o ranking",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Pontos: soma tudo que cada pessoa marcou", Value: "pontos"},
+					{Name: "Rating: quem chuta melhor que os outros, rodada a rodada", Value: "rating"},
+				},
+			},
+		},
+	},
+	{
+		Name:                     "jogar_em_times",
+		Description:              "Liga ou desliga o modo em times, onde os pontos de cada pessoa também contam para o seu time",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "ligado",
+				Description: "Se o servidor joga em times",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:                     "criar_time",
+		Description:              "Cria um time no servidor",
+		DefaultMemberPermissions: &adminPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "nome",
+				Description: "Nome do time",
+				MaxLength:   32,
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionRole,
+				Name:        "cargo",
+				Description: "Quem tiver esse cargo entra no time automaticamente",
+			},
+		},
+	},
+	{
+		Name:        "times",
+		Description: "Lista os times do servidor",
+	},
+	{
+		Name:        "entrar_time",
+		Description: "Entra em um time, saindo do time atual",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "nome",
+				Description: "Nome do time",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "sair_time",
+		Description: "Sai do seu time",
+	},
+	{
+		Name:        "apostar",
+		Description: "Aposta moedas em um chute. Quanto mais perto do preço, mais moedas você ganha",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "chute",
+				Description: "Seu chute para o preço do anúncio",
+				MinValue:    &minAdPrice,
+				MaxValue:    olx.OLX_MAX_PRICE,
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "moedas",
+				Description: "Quantas moedas apostar",
+				MinValue:    &minStake,
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "carteira",
+		Description: "Mostra suas moedas e as últimas movimentações",
+	},
+	{
+		Name:        "diaria",
+		Description: "Pega suas moedas do dia",
+	},
+	{
+		Name:        "desafio",
+		Description: "O desafio do dia: o mesmo anúncio para todos os servidores, com poucas tentativas",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "chute",
+				Description: "Seu chute para o preço do anúncio do desafio",
+				MinValue:    &minAdPrice,
+				MaxValue:    olx.OLX_MAX_PRICE,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "ranking",
+				Description: "Mostra o ranking do desafio de hoje, com gente de todos os servidores",
+			},
+		},
+	},
+	{
+		Name:        "duelo",
+		Description: "Desafia alguém para um duelo: quem chegar mais perto do preço do mesmo anúncio ganha",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "oponente",
+				Description: "Quem você quer desafiar",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "duelo_anuncio",
+		Description: "Mostra o anúncio do seu duelo, só para você",
+	},
+	{
+		Name:        "duelo_chute",
+		Description: "Chuta o preço do anúncio do seu duelo",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "valor",
+				Description: "Seu chute",
+				MinValue:    &minAdPrice,
+				MaxValue:    olx.OLX_MAX_PRICE,
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "perfil",
+		Description: "Mostra os duelos, moedas e time de alguém",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "usuario",
+				Description: "De quem é o perfil (você, se não escolher ninguém)",
+			},
+		},
+	},
+	{
+		Name:        "stats",
+		Description: "Mostra as estatísticas de alguém: chutes, vitórias, erro médio e mais",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "usuario",
+				Description: "De quem são as estatísticas (você, se não escolher ninguém)",
+			},
+		},
+	},
+	{
+		Name:        "conquistas",
+		Description: "Mostra as conquistas que alguém desbloqueou",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "usuario",
+				Description: "De quem são as conquistas (você, se não escolher ninguém)",
+			},
+		},
 	},
 	{
 		Name:        "categorias",
@@ -1,17 +1,14 @@
 package discord
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"log"
-	"math/rand/v2"
 	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/gabrieleiro/olx-bets/bot/db"
 	"github.com/gabrieleiro/olx-bets/bot/game"
 	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
@@ -115,19 +112,6 @@ func ParseGuess(msg *discordgo.MessageCreate) (int, error) {
 	return guess, nil
 }
 
-func countZeroes(n int) int {
-	var zeroes int
-	for n > 0 {
-		if n%10 == 0 {
-			zeroes++
-		}
-
-		n /= 10
-	}
-
-	return zeroes
-}
-
 func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m.Author.ID == s.State.User.ID {
 		return
@@ -145,7 +129,13 @@ func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
-	if channelId != game.InstanceChannel(guildId) {
+	gameId, ok := game.GameIn(guildId, channelId)
+	if !ok {
+		return
+	}
+
+	// rounds with a thread only take guesses from there
+	if thread := game.RoundThread(gameId); thread != 0 && thread != channelId {
 		return
 	}
 
@@ -154,132 +144,280 @@ func MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return
 	}
 
-	isRight, err := game.CheckGuess(m.Author.Username, guess, guildId)
+	userId, err := strconv.Atoi(m.Author.ID)
+	if err != nil {
+		log.Printf("error parsing user id %v\n", m.Author.ID)
+		return
+	}
+
+	if game.TeamsEnabled(guildId) && m.Member != nil {
+		joinTeamByRoles(guildId, m.Author.Username, m.Member.Roles)
+	}
+
+	isRight, err := game.CheckGuess(userId, m.Author.Username, guess, gameId)
 	if err != nil {
 		if errors.Is(err, game.ErrRoundClosed) {
 			log.Printf("round closed\n")
 			return
 		}
 
+		if errors.Is(err, game.ErrNoGuessesLeft) {
+			Session().MessageReactionAdd(m.ChannelID, m.ID, "🚫")
+			return
+		}
+
 		log.Printf("Checking if guess is right: %v\n", err)
 		return
 	}
 
+	// the round was already announced and the next one started
 	if isRight {
-		ad := game.Ad(guildId)
-
-		_, err := Session().ChannelMessageSendEmbed(m.ChannelID, &discordgo.MessageEmbed{
-			Title:       fmt.Sprintf("%s acertou!", m.Author.Username),
-			Description: fmt.Sprintf("%s está a venda por R$ %d", ad.Title, ad.Price),
-		})
-
-		if err != nil {
-			log.Printf("sending discord message for item found: %v\n", err)
-		}
+		return
+	}
 
-		err = game.NewRound(guildId)
-		if err != nil {
-			SendEmbedInChannel(m.ChannelID, m.GuildID, ops)
-			return
+	// price is right rounds are played blind, there's
+	// no feedback on how close a guess was
+	if game.GameMode(gameId) == game.ModePriceIsRight {
+		Session().MessageReactionAdd(m.ChannelID, m.ID, "✅")
+		if game.GuessesLeft(gameId, m.Author.Username) == 0 {
+			RespondWithEmbed(m, "Esse foi seu último chute nessa rodada")
 		}
 
-		go game.ScoreFor(m.Author.Username, guildId)
+		return
+	}
 
-		SendEmbedInChannel(m.ChannelID, m.GuildID, "Começando nova rodada")
-		SendAdInChannel(m.ChannelID, m.GuildID, game.Ad(guildId))
+	isClose, err := game.IsClose(guess, gameId)
+	if err != nil {
+		log.Printf("Checking if guess %d is close: %v", guess, err)
+		return
+	}
 
-		game.OpenRound(guildId)
+	if isClose {
+		RespondWithEmbed(m, "Quase!")
 		return
 	}
 
-	guessCount := game.GuessCount(guildId)
-	if guessCount == 15 {
-		ad := game.Ad(guildId)
-		zeroes := countZeroes(ad.Price)
-		if zeroes == 0 {
-			go SendEmbedInChannel(m.ChannelID, m.GuildID, "Dica: Não tem nenhum zero no preço desse anúncio")
-		} else if zeroes == 1 {
-			go SendEmbedInChannel(m.ChannelID, m.GuildID, "Dica: Tem um zero no preço desse anúncio")
-		} else {
-			hint := fmt.Sprintf("Dica: Tem %d zeros no preço desse anúncio", zeroes)
-			go SendEmbedInChannel(m.ChannelID, m.GuildID, hint)
+	isWayOff := game.IsWayOff(guess, gameId)
+	if isWayOff {
+		Session().MessageReactionAdd(m.ChannelID, m.ID, "🥶")
+	}
+}
+
+// joinTeamByRoles puts users in the team mapped to one of their roles, if they don't have a team yet
+func joinTeamByRoles(guildId int, username string, roles []string) {
+	var roleIds []int
+	for _, r := range roles {
+		id, err := strconv.Atoi(r)
+		if err != nil {
+			log.Printf("parsing role id %s: %v\n", r, err)
+			continue
 		}
+
+		roleIds = append(roleIds, id)
 	}
 
-	if guessCount > 10 {
-		go func() {
-			diceRoll := rand.N(100)
+	err := game.JoinTeamByRoles(guildId, username, roleIds)
+	if err != nil {
+		log.Printf("joining team by roles in guild %d: %v\n", guildId, err)
+	}
+}
 
-			if diceRoll <= 5 {
-				otherItem, err := game.SamePrice(guildId)
-				ad := game.Ad(guildId)
-				if err == nil {
-					hint := fmt.Sprintf("**%s** tem o mesmo preço de **%s**", ad.Title, otherItem)
-					go SendEmbedInChannel(m.ChannelID, m.GuildID, hint)
-				}
-			}
-		}()
+// GameEvent posts what happens in the game of a guild to its channel
+func GameEvent(e game.Event) {
+	// duels are announced where they were started
+	if e, ok := e.(game.DuelFinished); ok {
+		duelFinished(e.Duel, e.CalledOff)
+		return
+	}
+
+	guildId := e.Guild()
+	if !game.IsChannelSet(guildId) {
+		return
 	}
 
-	if (guessCount > 0) && ((guessCount % 10) == 0) {
-		closest, err := game.ClosestGuess(guildId)
+	channelId := strconv.Itoa(game.InstanceChannel(guildId))
+	guildIdStr := strconv.Itoa(guildId)
+
+	switch e := e.(type) {
+	case game.RoundStarted:
+		SendEmbedInChannel(channelId, guildIdStr, fmt.Sprintf("Começando a rodada #%d", e.Number))
+		msg := SendAdInChannel(channelId, guildIdStr, e.Ad)
+		if msg != nil && game.RoundThreads(guildId) {
+			startRoundThread(guildId, e.Number, msg)
+		}
+	case game.HintUnlocked:
+		go SendEmbedInChannel(roundChannel(channelId, e.Thread), guildIdStr, e.Hint.Text)
+	case game.RoundWon:
+		roundWon(roundChannel(channelId, e.Thread), e)
+		archiveThread(e.Thread, "")
+	case game.RoundExpired:
+		roundExpired(roundChannel(channelId, e.Thread), e)
+		archiveThread(e.Thread, "")
+	case game.RoundSkipped:
+		SendEmbedInChannel(channelId, guildIdStr, fmt.Sprintf("Rodada #%d pulada! %s estava a venda por R$ %d", e.Number, e.Ad.Title, e.Ad.Price))
+		archiveThread(e.Thread, fmt.Sprintf("Rodada #%d pulada!", e.Number))
+	case game.BadgeUnlocked:
+		// the round's thread might be archived by now
+		embed := BadgeEmbed(e.Username, e.Badge)
+		_, err := Session().ChannelMessageSendEmbed(channelId, &embed)
 		if err != nil {
-			log.Printf("Hinting closest guess: %v\n", err)
-			return
+			log.Printf("sending discord message for unlocked badge: %v\n", err)
 		}
+	}
+}
+
+// roundChannel is where messages about a round go: its thread, if it has one
+func roundChannel(channelId string, thread int) string {
+	if thread != 0 {
+		return strconv.Itoa(thread)
+	}
+
+	return channelId
+}
 
-		hint := fmt.Sprintf("%s foi quem passou mais perto com R$ %d", closest.Username, closest.Value)
-		SendEmbedInChannel(m.ChannelID, m.GuildID, hint)
+// how long a round's thread stays open without messages, in minutes
+const threadArchiveDuration = 24 * 60
+
+// startRoundThread opens a thread from the message with the ad of the round,
+// where the guesses of the round go from then on
+func startRoundThread(guildId int, number int, msg *discordgo.Message) {
+	thread, err := Session().MessageThreadStart(msg.ChannelID, msg.ID, fmt.Sprintf("Rodada #%d", number), threadArchiveDuration)
+	if err != nil {
+		log.Printf("starting thread of round %d in guild %d: %v\n", number, guildId, err)
 		return
 	}
 
-	isClose, err := game.IsClose(guess, guildId)
+	threadId, err := strconv.Atoi(thread.ID)
 	if err != nil {
-		log.Printf("Checking if guess %d is close: %v", guess, err)
+		log.Printf("parsing thread id %s: %v\n", thread.ID, err)
 		return
 	}
 
-	if isClose {
-		RespondWithEmbed(m, "Quase!")
+	err = game.SetRoundThread(guildId, number, threadId)
+	if err != nil {
+		log.Printf("setting thread of round %d in guild %d: %v\n", number, guildId, err)
+	}
+}
+
+// archiveThread closes the thread of a round that's over, leaving
+// a last message in it if there's one
+func archiveThread(thread int, message string) {
+	if thread == 0 {
 		return
 	}
 
-	isWayOff := game.IsWayOff(guess, guildId)
-	if isWayOff {
-		Session().MessageReactionAdd(m.ChannelID, m.ID, "🥶")
+	threadId := strconv.Itoa(thread)
+	if message != "" {
+		_, err := Session().ChannelMessageSendEmbed(threadId, &discordgo.MessageEmbed{Description: message})
+		if err != nil {
+			log.Printf("sending last message to thread %s: %v\n", threadId, err)
+		}
+	}
+
+	archived, locked := true, true
+	_, err := Session().ChannelEdit(threadId, &discordgo.ChannelEdit{
+		Archived: &archived,
+		Locked:   &locked,
+	})
+	if err != nil {
+		log.Printf("archiving thread %s: %v\n", threadId, err)
 	}
 }
 
-func GuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
-	tx, err := db.Conn.BeginTx(context.Background(), nil)
+func roundWon(channelId string, e game.RoundWon) {
+	description := fmt.Sprintf("%s está a venda por R$ %d", e.Ad.Title, e.Ad.Price)
+	title := fmt.Sprintf("%s acertou!", e.Winner)
+	if e.Guess != e.Ad.Price {
+		title = fmt.Sprintf("%s chegou perto o bastante!", e.Winner)
+		description += "\n" + winDistanceDescription(e.Guess, e.Ad.Price)
+	}
+
+	if e.Team != "" {
+		description += fmt.Sprintf("\n**Ponto para o time %s!**", e.Team)
+	}
+
+	if e.Standings != nil {
+		description += standingsDescription(e.Standings)
+	}
+
+	_, err := Session().ChannelMessageSendEmbed(channelId, &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description + scoresDescription(e.Scores) + betsDescription(e.Bets),
+	})
 	if err != nil {
-		log.Printf("could not register guild %s: %v\n", g.ID, err)
-		return
+		log.Printf("sending discord message for item found: %v\n", err)
+	}
+}
+
+// roundExpired reveals the price of a round nobody got right in time
+func roundExpired(channelId string, e game.RoundExpired) {
+	var description strings.Builder
+	description.WriteString(fmt.Sprintf("%s está a venda por R$ %d", e.Ad.Title, e.Ad.Price))
+
+	if game.GameMode(e.GuildId) == game.ModePriceIsRight {
+		if len(e.Standings) == 0 {
+			description.WriteString("\nNinguém chutou nessa rodada")
+		} else if e.Standings[0].Over {
+			description.WriteString("\nTodo mundo estourou o preço!")
+		} else {
+			description.WriteString(fmt.Sprintf("\n**%s venceu com R$ %d**", e.Standings[0].Username, e.Standings[0].Guess))
+		}
+
+		if e.Team != "" {
+			description.WriteString(fmt.Sprintf("\n**Ponto para o time %s!**", e.Team))
+		}
+
+		description.WriteString(standingsDescription(e.Standings))
+	} else if e.Closest != nil {
+		description.WriteString(fmt.Sprintf("\n%s foi quem passou mais perto com R$ %d", e.Closest.Username, e.Closest.Value))
+	} else {
+		description.WriteString("\nNinguém chutou nessa rodada")
 	}
-	defer tx.Rollback()
 
-	_, err = db.Conn.Exec(`
-		INSERT INTO guilds(discord_id)
-		VALUES (?)
-		ON CONFLICT do nothing;
-	`, g.ID)
+	description.WriteString(scoresDescription(e.Scores))
+	description.WriteString(betsDescription(e.Bets))
 
+	_, err := Session().ChannelMessageSendEmbed(channelId, &discordgo.MessageEmbed{
+		Title:       "Tempo esgotado!",
+		Description: description.String(),
+	})
 	if err != nil {
-		log.Printf("could not register guild %s: %v\n", g.ID, err)
-		return
+		log.Printf("sending discord message for expired round: %v\n", err)
 	}
+}
 
-	err = tx.Commit()
+func duelFinished(duel game.Duel, calledOff bool) {
+	var description strings.Builder
+	description.WriteString(fmt.Sprintf("%s está a venda por R$ %d\n", duel.Ad.Title, duel.Ad.Price))
+	description.WriteString(duelGuessDescription(duel.Challenger, duel.ChallengerGuesses, duel.Ad.Price))
+	description.WriteString(duelGuessDescription(duel.Opponent, duel.OpponentGuesses, duel.Ad.Price))
+
+	title := fmt.Sprintf("%s venceu o duelo!", duel.Winner)
+	switch {
+	case calledOff:
+		title = "O duelo foi cancelado porque alguém não chutou"
+	case duel.Winner == "":
+		title = "O duelo empatou!"
+	}
+
+	_, err := Session().ChannelMessageSendEmbed(strconv.Itoa(duel.ChannelId), &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description.String(),
+	})
 	if err != nil {
-		log.Printf("could not register guild %s: %v\n", g.ID, err)
-		return
+		log.Printf("sending discord message for finished duel: %v\n", err)
 	}
+}
 
+func GuildCreate(s *discordgo.Session, g *discordgo.GuildCreate) {
 	guildId, err := strconv.Atoi(g.ID)
 	if err != nil {
 		log.Printf("parsing guild id %s", g.ID)
 		return
 	}
 
-	game.NewInstance(guildId)
+	err = game.NewInstance(guildId)
+	if err != nil {
+		log.Printf("could not register guild %s: %v\n", g.ID, err)
+	}
 }
@@ -1,10 +1,13 @@
 package discord
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
-	"github.com/gabrieleiro/olx-bets/bot/olx"
 	"github.com/bwmarrin/discordgo"
+	"github.com/gabrieleiro/olx-bets/bot/game"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
 
 func AdEmbed(ad olx.OLXAd) discordgo.MessageEmbed {
@@ -17,6 +20,178 @@ func AdEmbed(ad olx.OLXAd) discordgo.MessageEmbed {
 	}
 }
 
+// scoresDescription lists the points given out in a round, meant to be
+// appended to the description of the embed announcing the round's end
+func scoresDescription(scores []game.RoundScore) string {
+	if len(scores) == 0 {
+		return ""
+	}
+
+	var res strings.Builder
+	res.WriteString("\n\n**Pontos da rodada**")
+	for _, sc := range scores {
+		res.WriteString(fmt.Sprintf("\n%s chutou R$ %d (+%d)", sc.Username, sc.Guess, sc.Points))
+	}
+
+	return res.String()
+}
+
+// standingsDescription lists every guesser of a price is right round
+func standingsDescription(standings []game.Standing) string {
+	if len(standings) == 0 {
+		return ""
+	}
+
+	var res strings.Builder
+	res.WriteString("\n\n**Classificação**")
+	for idx, st := range standings {
+		res.WriteString(fmt.Sprintf("\n#%d %s R$ %d", idx+1, st.Username, st.Guess))
+		if st.Over {
+			res.WriteString(" (estourou)")
+		}
+	}
+
+	return res.String()
+}
+
+func ChallengeEmbed(ad olx.OLXAd, attemptsLeft int) discordgo.MessageEmbed {
+	embed := AdEmbed(ad)
+	embed.Author = &discordgo.MessageEmbedAuthor{Name: "Desafio do dia"}
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("Você tem %d de %d tentativas. Chute com /desafio chute", attemptsLeft, game.ChallengeAttempts),
+	}
+
+	return embed
+}
+
+// duelGuessDescription tells how close the best guess of a duel player got
+func duelGuessDescription(username string, guesses []int, price int) string {
+	best, ok := game.BestDuelGuess(guesses, price)
+	if !ok {
+		return fmt.Sprintf("\n%s não chutou", username)
+	}
+
+	return fmt.Sprintf("\n%s chutou R$ %d (%.1f%% de diferença)", username, best, game.PercentDiff(best, price))
+}
+
+type badgeInfo struct {
+	Emoji       string
+	Name        string
+	Description string
+}
+
+var badges = map[game.Badge]badgeInfo{
+	game.BadgeFirstWin:      {"🥇", "Primeira vitória", "Ganhe uma rodada"},
+	game.BadgeTenWins:       {"🏆", "Veterano", "Ganhe 10 rodadas"},
+	game.BadgeFirstTry:      {"🎯", "De primeira", "Ganhe uma rodada com um chute só"},
+	game.BadgeBigCar:        {"🏎️", "Olho de mecânico", "Acerte em cheio o preço de um carro de mais de R$ 50 mil"},
+	game.BadgeAllCategories: {"🌎", "Generalista", "Ganhe rodadas de todas as categorias"},
+}
+
+func BadgeEmbed(username string, badge game.Badge) discordgo.MessageEmbed {
+	info := badges[badge]
+
+	return discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s %s desbloqueou uma conquista!", info.Emoji, username),
+		Description: fmt.Sprintf("**%s**\n%s", info.Name, info.Description),
+	}
+}
+
+// winDistanceDescription tells how far off a winning guess that wasn't the exact price was
+func winDistanceDescription(guess int, price int) string {
+	diff := guess - price
+	if diff < 0 {
+		diff = -diff
+	}
+
+	percent := 0.0
+	if price > 0 {
+		percent = float64(diff) * 100 / float64(price)
+	}
+
+	return fmt.Sprintf("O chute foi de R$ %d, R$ %d de diferença (%.1f%%)", guess, diff, percent)
+}
+
+// betsDescription lists how much each bet of a round paid
+func betsDescription(bets []game.Bet) string {
+	if len(bets) == 0 {
+		return ""
+	}
+
+	var res strings.Builder
+	res.WriteString("\n\n**Apostas**")
+	for _, b := range bets {
+		res.WriteString(fmt.Sprintf("\n%s apostou %d moedas em R$ %d", b.Username, b.Stake, b.Guess))
+		if b.Payout > 0 {
+			res.WriteString(fmt.Sprintf(" (+%d)", b.Payout))
+		} else {
+			res.WriteString(" (perdeu)")
+		}
+	}
+
+	return res.String()
+}
+
+// how many guesses of an archived round fit in its embed
+const historyGuessesShown = 15
+
+func HistoryEmbed(round game.ArchivedRound, page int, pages int) discordgo.MessageEmbed {
+	var description strings.Builder
+	description.WriteString(fmt.Sprintf("%s\nPreço: **R$ %d**\n\n", round.Ad.Location, round.Ad.Price))
+
+	switch {
+	case round.Winner != "" && round.EndReason == game.EndReasonWon:
+		description.WriteString(fmt.Sprintf("🏆 %s acertou o preço", round.Winner))
+	case round.Winner != "":
+		description.WriteString(fmt.Sprintf("🏆 %s venceu quando o tempo acabou", round.Winner))
+	case round.EndReason == game.EndReasonExpired:
+		description.WriteString("⏰ Ninguém acertou a tempo")
+	default:
+		description.WriteString("⏭️ Rodada pulada")
+	}
+
+	if !round.StartedAt.IsZero() {
+		description.WriteString(fmt.Sprintf("\nComeçou <t:%d:f>", round.StartedAt.Unix()))
+	}
+	description.WriteString(fmt.Sprintf("\nTerminou <t:%d:f>", round.EndedAt.Unix()))
+	description.WriteString(fmt.Sprintf("\n%d chutes", round.GuessCount))
+
+	var guesses strings.Builder
+	for idx, g := range round.Guesses {
+		if idx == historyGuessesShown {
+			guesses.WriteString(fmt.Sprintf("e mais %d chutes", len(round.Guesses)-historyGuessesShown))
+			break
+		}
+
+		if !g.CreatedAt.IsZero() {
+			guesses.WriteString(fmt.Sprintf("<t:%d:T> ", g.CreatedAt.Unix()))
+		}
+		guesses.WriteString(fmt.Sprintf("%s: R$ %d\n", g.Username, g.Value))
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("Rodada #%d: %s", round.Number, round.Ad.Title),
+		Description: description.String(),
+		Image: &discordgo.MessageEmbedImage{
+			URL: round.Ad.Image,
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Página %d de %d", page, pages),
+		},
+	}
+
+	if guesses.Len() > 0 {
+		embed.Fields = []*discordgo.MessageEmbedField{
+			{
+				Name:  "Chutes",
+				Value: guesses.String(),
+			},
+		}
+	}
+
+	return embed
+}
+
 func RespondInteractionWithAd(s *discordgo.Session, i *discordgo.InteractionCreate, ad olx.OLXAd) {
 	embed := AdEmbed(ad)
 
@@ -34,14 +209,16 @@ func RespondInteractionWithAd(s *discordgo.Session, i *discordgo.InteractionCrea
 	}
 }
 
-func SendAdInChannel(channel string, guild string, ad olx.OLXAd) {
+func SendAdInChannel(channel string, guild string, ad olx.OLXAd) *discordgo.Message {
 	embed := AdEmbed(ad)
 
-	_, err := session.ChannelMessageSendEmbed(channel, &embed)
+	msg, err := session.ChannelMessageSendEmbed(channel, &embed)
 
 	if err != nil {
 		log.Printf("could not send message in channel %s at server %s", channel, guild)
 	}
+
+	return msg
 }
 func SendEmbedInChannel(channel string, guild string, content string) {
 	_, err := session.ChannelMessageSendEmbed(channel, &discordgo.MessageEmbed{
@@ -70,6 +247,25 @@ func RespondInteractionWithEmbed(i *discordgo.InteractionCreate, content string)
 	}
 }
 
+// RespondInteractionPrivately answers with an embed only the user who interacted can see
+func RespondInteractionPrivately(i *discordgo.InteractionCreate, content string) {
+	err := session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Description: content,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		log.Printf("could not respond to interaction: %v\n", err)
+	}
+}
+
 func RespondWithEmbed(m *discordgo.MessageCreate, content string) {
 	_, err := session.ChannelMessageSendEmbedReply(m.ChannelID, &discordgo.MessageEmbed{
 		Type:        discordgo.EmbedTypeRich,
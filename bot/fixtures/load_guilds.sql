@@ -30,10 +30,44 @@ INSERT INTO
     olx_ads(id, title, price, location, image, category)
     VALUES ('3', 'iPhone XR 64Gb - Preto', '850', 'Angicos -  RN', 'https://img.olx.com.br/thumbs500x360/42/421519857063037.jpg', 'Eletrônicos e Celulares'); 
 INSERT INTO rounds(guild_id, ad_id) VALUES ('333261239926980867', '3');
-INSERT INTO guesses(guild_id, value, username)
+INSERT INTO guesses(guild_id, round_id, value, username)
     VALUES
-        ('333261239926980867', '120', 'gabrieleiro'),
-        ('333261239926980867', '20', 'gabrieleiro'),
-        ('333261239926980867', '999', 'gabrieleiro'),
-        ('333261239926980867', '12398', 'gabrieleiro');
+        ('333261239926980867', '3', '120', 'gabrieleiro'),
+        ('333261239926980867', '3', '20', 'gabrieleiro'),
+        ('333261239926980867', '3', '999', 'gabrieleiro'),
+        ('333261239926980867', '3', '12398', 'gabrieleiro');
 
+
+-- timed round
+INSERT INTO
+    guilds(discord_id, game_channel_id, round_timeout)
+    VALUES ('444261239926980123', '1440463177401962111', '30');
+INSERT INTO
+    olx_ads(id, title, price, location, image, category)
+    VALUES ('4', 'Bicicleta aro 29', '1200', 'Curitiba - PR', 'https://img.olx.com.br/thumbs500x360/12/121519857063037.jpg', 'Esportes e Lazer');
+INSERT INTO rounds(guild_id, ad_id, started_at, opened) VALUES ('444261239926980123', '4', strftime('%s', 'now'), '1');
+
+-- closed round with hints already given
+INSERT INTO
+    guilds(discord_id, game_channel_id)
+    VALUES ('555261239926980456', '1550463177401962222');
+INSERT INTO
+    olx_ads(id, title, price, location, image, category)
+    VALUES ('5', 'Guitarra Tagima', '1500', 'Recife - PE', 'https://img.olx.com.br/thumbs500x360/15/151519857063037.jpg', 'Música e Hobbies');
+INSERT INTO
+    rounds(guild_id, ad_id, started_at, opened, number, guess_count, hints, same_price, closest_username, closest_guess)
+    VALUES ('555261239926980456', '5', '1727740800', '0', '7', '20', 'zeros,closest:10', '2,3', 'gabrieleiro', '1450');
+INSERT INTO
+    hint_rules(guild_id, kind, guesses, every, elapsed, chance)
+    VALUES
+        ('555261239926980456', 'closest', '5', '5', '0', '100'),
+        ('555261239926980456', 'zeros', '0', '0', '300', '50');
+
+-- finished round
+INSERT INTO
+    rounds(id, guild_id, ad_id, started_at, number, guess_count, ended_at, end_reason, winner)
+    VALUES ('6', '555261239926980456', '1', '1727654400', '6', '2', '1727654700', 'acerto', 'gabrieleiro');
+INSERT INTO guesses(guild_id, round_id, value, username, created_at)
+    VALUES
+        ('555261239926980456', '6', '900', 'fulano', '1727654500'),
+        ('555261239926980456', '6', '950', 'gabrieleiro', '1727654700');
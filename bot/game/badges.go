@@ -0,0 +1,128 @@
+package game
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// Badge is an achievement users unlock by winning rounds
+type Badge string
+
+const (
+	BadgeFirstWin      Badge = "first_win"
+	BadgeTenWins       Badge = "ten_wins"
+	BadgeBigCar        Badge = "big_car"
+	BadgeFirstTry      Badge = "first_try"
+	BadgeAllCategories Badge = "all_categories"
+)
+
+// Badges are every badge there is, in the order they're shown
+var Badges = []Badge{BadgeFirstWin, BadgeTenWins, BadgeFirstTry, BadgeBigCar, BadgeAllCategories}
+
+// cars need to cost more than this to be worth BadgeBigCar
+const bigCarPrice = 50_000
+
+const carsCategory = "Carros, vans e utilitários"
+
+type UnlockedBadge struct {
+	Badge      Badge
+	UnlockedAt time.Time
+}
+
+// BadgeUnlocked is published once the badge is saved, which happens in the
+// background after the win that unlocked it
+type BadgeUnlocked struct {
+	GuildId  int
+	Username string
+	Badge    Badge
+}
+
+func (e BadgeUnlocked) Guild() int { return e.GuildId }
+
+// earnedBadges are the badges a win is worth. wins are the winner's wins by
+// category, this one included, and guesses how many guesses they took
+func earnedBadges(wins map[string]int, ad olx.OLXAd, guesses int, exact bool) []Badge {
+	total := 0
+	for _, count := range wins {
+		total += count
+	}
+
+	var earned []Badge
+	if total >= 1 {
+		earned = append(earned, BadgeFirstWin)
+	}
+
+	if total >= 10 {
+		earned = append(earned, BadgeTenWins)
+	}
+
+	if guesses == 1 {
+		earned = append(earned, BadgeFirstTry)
+	}
+
+	if exact && ad.Category == carsCategory && ad.Price > bigCarPrice {
+		earned = append(earned, BadgeBigCar)
+	}
+
+	allCategories := !slices.ContainsFunc(olx.Categories, func(c string) bool {
+		return wins[c] == 0
+	})
+	if allCategories {
+		earned = append(earned, BadgeAllCategories)
+	}
+
+	return earned
+}
+
+// awardBadges unlocks the badges the winner of the round that just closed
+// earned. Like the round's scores, they're saved in the background. It must
+// be called after the round is saved, so the win is counted
+func (gi *GameInstance) awardBadges(winner string, exact bool) {
+	if winner == "" || gi.round.ad == nil {
+		return
+	}
+
+	guesses := 0
+	for _, g := range gi.round.guesses {
+		if g.Username == winner {
+			guesses++
+		}
+	}
+
+	go gi.unlockBadges(winner, *gi.round.ad, guesses, exact)
+}
+
+// unlockBadges saves the badges a win earned and publishes the new ones
+func (gi *GameInstance) unlockBadges(winner string, ad olx.OLXAd, guesses int, exact bool) {
+	wins, err := gi.store.WinsByCategory(gi.guild(), winner)
+	if err != nil {
+		log.Printf("counting wins of %s in guild %d: %v\n", winner, gi.guild(), err)
+		return
+	}
+
+	now := time.Now()
+	for _, badge := range earnedBadges(wins, ad, guesses, exact) {
+		isNew, err := gi.store.UnlockBadge(gi.guild(), winner, badge, now)
+		if err != nil {
+			log.Printf("unlocking badge %s for %s in guild %d: %v\n", badge, winner, gi.guild(), err)
+			continue
+		}
+
+		if isNew {
+			bus.Publish(BadgeUnlocked{GuildId: gi.guildId, Username: winner, Badge: badge})
+		}
+	}
+}
+
+// BadgesOf gets the badges the user unlocked in the guild
+func BadgesOf(guildId int, username string) ([]UnlockedBadge, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.Badges(guildId, username)
+}
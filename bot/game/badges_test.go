@@ -0,0 +1,188 @@
+package game
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestEarnedBadges(t *testing.T) {
+	car := olx.OLXAd{Price: 62_000, Category: carsCategory}
+
+	everyCategory := make(map[string]int)
+	for _, c := range olx.Categories {
+		everyCategory[c] = 1
+	}
+
+	type TestMatch struct {
+		Name     string
+		Wins     map[string]int
+		Ad       olx.OLXAd
+		Guesses  int
+		Exact    bool
+		Expected []Badge
+	}
+
+	matches := []TestMatch{
+		{"first win", map[string]int{"Games": 1}, olx.OLXAd{Price: 300, Category: "Games"}, 4, true, []Badge{BadgeFirstWin}},
+		{"won with one guess", map[string]int{"Games": 2}, olx.OLXAd{Price: 300, Category: "Games"}, 1, true, []Badge{BadgeFirstWin, BadgeFirstTry}},
+		{"tenth win", map[string]int{"Games": 4, "Móveis": 6}, olx.OLXAd{Price: 300, Category: "Móveis"}, 2, true, []Badge{BadgeFirstWin, BadgeTenWins}},
+		{"exact hit on a car", map[string]int{carsCategory: 1}, car, 3, true, []Badge{BadgeFirstWin, BadgeBigCar}},
+		{"closest guess on a car", map[string]int{carsCategory: 1}, car, 3, false, []Badge{BadgeFirstWin}},
+		{"cheap car", map[string]int{carsCategory: 1}, olx.OLXAd{Price: 50_000, Category: carsCategory}, 3, true, []Badge{BadgeFirstWin}},
+		{"every category", everyCategory, car, 2, false, []Badge{BadgeFirstWin, BadgeTenWins, BadgeAllCategories}},
+	}
+
+	for _, m := range matches {
+		got := earnedBadges(m.Wins, m.Ad, m.Guesses, m.Exact)
+		if !slices.Equal(got, m.Expected) {
+			t.Fatalf("%s: badges mismatch\n  Want: %v\n  Got: %v\n", m.Name, m.Expected, got)
+		}
+	}
+}
+
+func TestAwardBadges(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Gol 1.0 2015", Price: 52_000, Category: carsCategory},
+		{Id: 2, Title: "Honda Civic 2018", Price: 98_000, Category: carsCategory},
+	}, guildId)
+
+	var mu sync.Mutex
+	var unlocked []Badge
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(BadgeUnlocked); ok && e.GuildId == guildId && e.Username == "ana" {
+			mu.Lock()
+			defer mu.Unlock()
+
+			unlocked = append(unlocked, e.Badge)
+		}
+	})
+	defer unsubscribe()
+
+	// waitForBadges waits for the badges unlocked in the background
+	waitForBadges := func(count int) []Badge {
+		t.Helper()
+
+		eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+
+			return len(unlocked) >= count
+		})
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		return slices.Clone(unlocked)
+	}
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	for _, guess := range []int{Ad(guildId).Price + 1000, Ad(guildId).Price} {
+		_, err = CheckGuess(0, "ana", guess, guildId)
+		if err != nil {
+			t.Fatalf("checking guess: %v\n", err)
+		}
+	}
+
+	expected := []Badge{BadgeFirstWin, BadgeBigCar}
+	if got := waitForBadges(len(expected)); !slices.Equal(got, expected) {
+		t.Fatalf("unlocked badges mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	// badges are only unlocked once, so only the new one is announced
+	_, err = CheckGuess(0, "ana", Ad(guildId).Price, guildId)
+	if err != nil {
+		t.Fatalf("checking guess: %v\n", err)
+	}
+
+	expected = append(expected, BadgeFirstTry)
+	if got := waitForBadges(len(expected)); !slices.Equal(got, expected) {
+		t.Fatalf("badges were unlocked twice\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	got, err := BadgesOf(guildId, "ana")
+	if err != nil {
+		t.Fatalf("fetching badges: %v\n", err)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("saved badges mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+}
+
+func TestSQLStoreBadges(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	for _, badge := range []Badge{BadgeFirstWin, BadgeFirstTry, BadgeFirstWin} {
+		_, err := store.UnlockBadge(guildId, "badge_hunter", badge, time.Now())
+		if err != nil {
+			t.Fatalf("unlocking badge: %v\n", err)
+		}
+	}
+
+	isNew, err := store.UnlockBadge(guildId, "badge_hunter", BadgeFirstTry, time.Now())
+	if err != nil || isNew {
+		t.Fatalf("badge was unlocked twice: %v %v\n", isNew, err)
+	}
+
+	got, err := store.Badges(guildId, "badge_hunter")
+	if err != nil {
+		t.Fatalf("fetching badges: %v\n", err)
+	}
+
+	expected := []Badge{BadgeFirstWin, BadgeFirstTry}
+	if len(got) != len(expected) {
+		t.Fatalf("badges mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for idx := range expected {
+		if got[idx].Badge != expected[idx] {
+			t.Fatalf("badge #%d mismatch\n  Want: %v\n  Got: %v\n", idx+1, expected[idx], got[idx].Badge)
+		}
+	}
+
+	ad, err := store.RandomAd(guildId, PriceRange{})
+	if err != nil {
+		t.Fatalf("fetching ad: %v\n", err)
+	}
+
+	now := time.Now()
+	roundId, number, err := store.StartRound(guildId, ad.Id, now)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	err = store.SaveRound(RoundState{
+		Id:        roundId,
+		GuildId:   guildId,
+		Number:    number,
+		Ad:        ad,
+		StartedAt: now,
+		EndedAt:   now,
+		EndReason: EndReasonWon,
+		Winner:    "badge_hunter",
+	})
+	if err != nil {
+		t.Fatalf("saving round: %v\n", err)
+	}
+
+	wins, err := store.WinsByCategory(guildId, "badge_hunter")
+	if err != nil {
+		t.Fatalf("counting wins: %v\n", err)
+	}
+
+	if len(wins) != 1 || wins[ad.Category] != 1 {
+		t.Fatalf("wins mismatch\n  Want: map[%s:1]\n  Got: %v\n", ad.Category, wins)
+	}
+}
@@ -0,0 +1,290 @@
+package game
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// ChallengeAttempts is how many guesses each user gets in a daily challenge
+const ChallengeAttempts = 5
+
+// the challenge picks its ads through RandomAd as if it were a guild of
+// its own, so no ad comes back until every other one was a challenge
+const challengeGuild = 0
+
+// challenges are played by everyone, so they stay away from the extremes
+var challengePrices = difficultyRanges[DifficultyMedium]
+
+var ErrNoChallenge = errors.New("no challenge for this day")
+var ErrNoAttemptsLeft = errors.New("no attempts left in today's challenge")
+var ErrChallengeSolved = errors.New("user already solved today's challenge")
+
+type ChallengeAttempt struct {
+	Day       time.Time
+	GuildId   int
+	Username  string
+	Guess     int
+	CreatedAt time.Time
+}
+
+// ChallengeFeedback is what users learn from each attempt
+type ChallengeFeedback struct {
+	Solved bool
+	// Higher is whether the price is above the guess
+	Higher       bool
+	AttemptsLeft int
+}
+
+// ChallengeStanding is the result of a user in a daily challenge.
+// Users are ranked by the guild they first played from
+type ChallengeStanding struct {
+	GuildId  int
+	Username string
+	Attempts int
+	// Best is the closest guess of the user
+	Best   int
+	Solved bool
+	// BestAt is when the best guess was made, which breaks ties
+	BestAt time.Time
+}
+
+// dailyChallenge is the challenge of the day, shared by every guild. It's
+// loaded the first time it's used each day, so it resets at midnight in São Paulo
+type dailyChallenge struct {
+	mu       sync.Mutex
+	day      time.Time
+	ad       *olx.OLXAd
+	attempts []ChallengeAttempt
+}
+
+var challenge = &dailyChallenge{}
+
+// reset forgets the loaded challenge, so the next use loads it from the store
+func (c *dailyChallenge) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ad = nil
+	c.attempts = nil
+}
+
+// load makes sure c is the challenge of the day of now,
+// picking its ad if nobody played that day yet. Must hold c.mu
+func (c *dailyChallenge) load(store Store, now time.Time) error {
+	if store == nil {
+		return ErrNoInstance
+	}
+
+	day := startOfDay(now)
+	if c.ad != nil && c.day.Equal(day) {
+		return nil
+	}
+
+	ad, err := store.Challenge(day)
+	if errors.Is(err, ErrNoChallenge) {
+		ad, err = challengeAd(store)
+		if err != nil {
+			return err
+		}
+
+		err = store.StartChallenge(day, ad.Id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	attempts, err := store.ChallengeAttempts(day)
+	if err != nil {
+		return err
+	}
+
+	c.day = day
+	c.ad = &ad
+	c.attempts = attempts
+
+	return nil
+}
+
+func challengeAd(store Store) (olx.OLXAd, error) {
+	ad, err := store.RandomAd(challengeGuild, challengePrices)
+	if !errors.Is(err, ErrNoAds) {
+		return ad, err
+	}
+
+	err = store.ResetSeenAds(challengeGuild)
+	if err != nil {
+		return ad, err
+	}
+
+	return store.RandomAd(challengeGuild, challengePrices)
+}
+
+func (c *dailyChallenge) attemptsOf(username string) []ChallengeAttempt {
+	var res []ChallengeAttempt
+	for _, a := range c.attempts {
+		if a.Username == username {
+			res = append(res, a)
+		}
+	}
+
+	return res
+}
+
+// challengeSolved tells whether a guess is good enough for the challenge.
+// Users only get a few attempts, so getting close counts
+func challengeSolved(guess int, price int) bool {
+	return isClose(guess, price)
+}
+
+func (c *dailyChallenge) guess(store Store, now time.Time, guildId int, username string, guess int) (ChallengeFeedback, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.load(store, now)
+	if err != nil {
+		return ChallengeFeedback{}, err
+	}
+
+	previous := c.attemptsOf(username)
+	if slices.ContainsFunc(previous, func(a ChallengeAttempt) bool { return challengeSolved(a.Guess, c.ad.Price) }) {
+		return ChallengeFeedback{}, ErrChallengeSolved
+	}
+
+	if len(previous) >= ChallengeAttempts {
+		return ChallengeFeedback{}, ErrNoAttemptsLeft
+	}
+
+	attempt := ChallengeAttempt{
+		Day:       c.day,
+		GuildId:   guildId,
+		Username:  username,
+		Guess:     guess,
+		CreatedAt: now,
+	}
+	c.attempts = append(c.attempts, attempt)
+
+	go func() {
+		err := store.AddChallengeAttempt(attempt)
+		if err != nil {
+			log.Printf("saving challenge attempt of %s: %v\n", username, err)
+		}
+	}()
+
+	return ChallengeFeedback{
+		Solved:       challengeSolved(guess, c.ad.Price),
+		Higher:       c.ad.Price > guess,
+		AttemptsLeft: ChallengeAttempts - len(previous) - 1,
+	}, nil
+}
+
+// challengeStandings ranks who solved the challenge by how many attempts
+// they took, then everyone else by how close they got
+func challengeStandings(attempts []ChallengeAttempt, price int) []ChallengeStanding {
+	var res []ChallengeStanding
+	positions := make(map[string]int)
+
+	for _, a := range attempts {
+		pos, ok := positions[a.Username]
+		if !ok {
+			positions[a.Username] = len(res)
+			res = append(res, ChallengeStanding{
+				GuildId:  a.GuildId,
+				Username: a.Username,
+				Best:     a.Guess,
+				BestAt:   a.CreatedAt,
+			})
+			pos = len(res) - 1
+		}
+
+		st := &res[pos]
+		if st.Solved {
+			continue
+		}
+
+		st.Attempts++
+		if math.Abs(float64(a.Guess-price)) < math.Abs(float64(st.Best-price)) {
+			st.Best = a.Guess
+			st.BestAt = a.CreatedAt
+		}
+		st.Solved = challengeSolved(st.Best, price)
+	}
+
+	slices.SortStableFunc(res, func(a, b ChallengeStanding) int {
+		if a.Solved != b.Solved {
+			if a.Solved {
+				return -1
+			}
+			return 1
+		}
+
+		if a.Solved {
+			return cmp.Or(cmp.Compare(a.Attempts, b.Attempts), a.BestAt.Compare(b.BestAt))
+		}
+
+		return cmp.Or(
+			cmp.Compare(math.Abs(float64(a.Best-price)), math.Abs(float64(b.Best-price))),
+			a.BestAt.Compare(b.BestAt),
+		)
+	})
+
+	return res
+}
+
+func (c *dailyChallenge) standings(store Store, now time.Time) ([]ChallengeStanding, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.load(store, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return challengeStandings(c.attempts, c.ad.Price), nil
+}
+
+// Challenge gets the ad of today's challenge
+func Challenge() (olx.OLXAd, error) {
+	c := challenge
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.load(instances.defaultStore(), time.Now())
+	if err != nil {
+		return olx.OLXAd{}, err
+	}
+
+	return *c.ad, nil
+}
+
+// ChallengeAttemptsLeft is how many guesses the user still has in today's challenge
+func ChallengeAttemptsLeft(username string) (int, error) {
+	c := challenge
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.load(instances.defaultStore(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return ChallengeAttempts - len(c.attemptsOf(username)), nil
+}
+
+// ChallengeGuess is an attempt at today's challenge. Users play it from
+// any guild, but their attempts are counted together
+func ChallengeGuess(guildId int, username string, guess int) (ChallengeFeedback, error) {
+	return challenge.guess(instances.defaultStore(), time.Now(), guildId, username, guess)
+}
+
+// ChallengeLeaderboard ranks everyone who played today's challenge, from every guild
+func ChallengeLeaderboard() ([]ChallengeStanding, error) {
+	return challenge.standings(instances.defaultStore(), time.Now())
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestChallengeStandings(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2024, 3, 10, 12, minute, 0, 0, saoPaulo)
+	}
+
+	attempts := []ChallengeAttempt{
+		{GuildId: 1, Username: "ana", Guess: 500, CreatedAt: at(0)},
+		{GuildId: 2, Username: "bia", Guess: 1000, CreatedAt: at(1)},
+		{GuildId: 1, Username: "caio", Guess: 900, CreatedAt: at(2)},
+		{GuildId: 1, Username: "ana", Guess: 1010, CreatedAt: at(3)},
+		{GuildId: 2, Username: "davi", Guess: 2000, CreatedAt: at(4)},
+		{GuildId: 1, Username: "caio", Guess: 700, CreatedAt: at(5)},
+	}
+
+	expected := []ChallengeStanding{
+		{GuildId: 2, Username: "bia", Attempts: 1, Best: 1000, Solved: true, BestAt: at(1)},
+		{GuildId: 1, Username: "ana", Attempts: 2, Best: 1010, Solved: true, BestAt: at(3)},
+		{GuildId: 1, Username: "caio", Attempts: 2, Best: 900, BestAt: at(2)},
+		{GuildId: 2, Username: "davi", Attempts: 1, Best: 2000, BestAt: at(4)},
+	}
+
+	got := challengeStandings(attempts, 1000)
+	if len(got) != len(expected) {
+		t.Fatalf("challenge standings mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for idx := range expected {
+		if got[idx] != expected[idx] {
+			t.Fatalf("standing #%d mismatch\n  Want: %v\n  Got: %v\n", idx+1, expected[idx], got[idx])
+		}
+	}
+}
+
+func TestDailyChallenge(t *testing.T) {
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+		{Id: 2, Title: "iPhone XR 64Gb - Preto", Price: 850},
+	}, 1, 2)
+
+	c := &dailyChallenge{}
+	// 23:30 in São Paulo
+	night := time.Date(2024, 3, 10, 2, 30, 0, 0, time.UTC)
+
+	_, err := c.guess(store, night, 1, "ana", 1)
+	if err != nil {
+		t.Fatalf("guessing challenge: %v\n", err)
+	}
+
+	first := *c.ad
+	wrong := first.Price * 3
+
+	// attempts from another guild count for the same user
+	for range ChallengeAttempts - 1 {
+		feedback, err := c.guess(store, night, 2, "ana", wrong)
+		if err != nil || feedback.Solved || feedback.Higher {
+			t.Fatalf("wrong guess feedback mismatch\n  Got: %+v %v\n", feedback, err)
+		}
+	}
+
+	_, err = c.guess(store, night, 1, "ana", first.Price)
+	if err != ErrNoAttemptsLeft {
+		t.Fatalf("guess past the limit was accepted\n  Want: %v\n  Got: %v\n", ErrNoAttemptsLeft, err)
+	}
+
+	feedback, err := c.guess(store, night, 1, "bia", first.Price)
+	if err != nil || !feedback.Solved || feedback.AttemptsLeft != ChallengeAttempts-1 {
+		t.Fatalf("right guess feedback mismatch\n  Got: %+v %v\n", feedback, err)
+	}
+
+	_, err = c.guess(store, night, 1, "bia", first.Price)
+	if err != ErrChallengeSolved {
+		t.Fatalf("guess after solving was accepted\n  Want: %v\n  Got: %v\n", ErrChallengeSolved, err)
+	}
+
+	// a restart picks up the same challenge
+	eventually(t, func() bool {
+		attempts, err := store.ChallengeAttempts(startOfDay(night))
+		if err != nil {
+			t.Fatalf("fetching challenge attempts: %v\n", err)
+		}
+
+		return len(attempts) >= ChallengeAttempts+1
+	})
+	restarted := &dailyChallenge{}
+	standings, err := restarted.standings(store, night)
+	if err != nil {
+		t.Fatalf("fetching standings: %v\n", err)
+	}
+
+	if restarted.ad.Id != first.Id || len(standings) != 2 || standings[0].Username != "bia" {
+		t.Fatalf("challenge wasn't restored\n  Want: %v\n  Got: %v %v\n", first, *restarted.ad, standings)
+	}
+
+	// an hour later it's a new day in São Paulo
+	morning := night.Add(time.Hour)
+	_, err = c.guess(store, morning, 1, "ana", 1)
+	if err != nil {
+		t.Fatalf("guessing the next challenge: %v\n", err)
+	}
+
+	if c.ad.Id == first.Id {
+		t.Fatalf("next day repeated the ad %d\n", first.Id)
+	}
+}
+
+func TestSQLStoreChallenge(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	day := startOfDay(time.Now())
+
+	_, err := store.Challenge(day)
+	if err != ErrNoChallenge {
+		t.Fatalf("found challenge that wasn't started\n  Want: %v\n  Got: %v\n", ErrNoChallenge, err)
+	}
+
+	ad, err := challengeAd(store)
+	if err != nil {
+		t.Fatalf("picking challenge ad: %v\n", err)
+	}
+
+	err = store.StartChallenge(day, ad.Id)
+	if err != nil {
+		t.Fatalf("starting challenge: %v\n", err)
+	}
+
+	got, err := store.Challenge(day)
+	if err != nil || got.Id != ad.Id {
+		t.Fatalf("challenge ad mismatch\n  Want: %v\n  Got: %v %v\n", ad, got, err)
+	}
+
+	attempt := ChallengeAttempt{Day: day, GuildId: 555261239926980456, Username: "ana", Guess: 300, CreatedAt: time.Unix(time.Now().Unix(), 0)}
+	err = store.AddChallengeAttempt(attempt)
+	if err != nil {
+		t.Fatalf("adding attempt: %v\n", err)
+	}
+
+	attempts, err := store.ChallengeAttempts(day)
+	if err != nil || len(attempts) != 1 || !attempts[0].CreatedAt.Equal(attempt.CreatedAt) || attempts[0].Guess != attempt.Guess {
+		t.Fatalf("attempts mismatch\n  Want: %v\n  Got: %v %v\n", attempt, attempts, err)
+	}
+
+	attempts, err = store.ChallengeAttempts(day.AddDate(0, 0, 1))
+	if err != nil || len(attempts) != 0 {
+		t.Fatalf("attempts of another day mismatch\n  Got: %v %v\n", attempts, err)
+	}
+}
@@ -0,0 +1,169 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"slices"
+)
+
+// Guilds play their main game in the channel set with SetChannel, and can
+// run extra games in other channels, each with its own round and settings.
+// Extra games are instances of their own, registered under the id of their
+// channel. Discord ids are unique across guilds and channels, so they never
+// clash with the id of a guild
+
+var ErrChannelTaken = errors.New("channel already has a game")
+var ErrNoChannelGame = errors.New("channel has no game of its own")
+
+// ChannelGame is an extra game of a guild
+type ChannelGame struct {
+	ChannelId       int
+	SeparateRanking bool
+}
+
+// guild is the id of the guild the game belongs to
+func (gi *GameInstance) guild() int {
+	if gi.parentId != 0 {
+		return gi.parentId
+	}
+
+	return gi.guildId
+}
+
+// rankingId is the ranking the game's scores go to
+func (gi *GameInstance) rankingId() int {
+	if gi.separateRanking {
+		return gi.guildId
+	}
+
+	return gi.guild()
+}
+
+// teamsOn tells whether the guild of the game plays in teams. Must hold gi.mu.
+// Extra games lock the instance of their guild, but never the other way around
+func (gi *GameInstance) teamsOn() bool {
+	if gi.parentId == 0 {
+		return gi.teamsEnabled
+	}
+
+	parent, ok := lockInstance(gi.parentId)
+	if !ok {
+		return false
+	}
+	defer parent.mu.Unlock()
+
+	return parent.teamsEnabled
+}
+
+// GameIn finds the game played in a channel of the guild, which
+// might also be the thread of a round
+func GameIn(guildId int, channelId int) (int, bool) {
+	if gi, ok := instances.threadGame(channelId); ok && gi.guild() == guildId {
+		return gi.guildId, true
+	}
+
+	if gi, ok := instances.get(channelId); ok && gi.parentId == guildId {
+		return channelId, true
+	}
+
+	if channelId != 0 && InstanceChannel(guildId) == channelId {
+		return guildId, true
+	}
+
+	return 0, false
+}
+
+// AddChannelGame starts an extra game of the guild in channelId, with the
+// settings and categories of the guild's main game. Its first round
+// starts with StartRound, like any other game
+func AddChannelGame(guildId int, channelId int, separateRanking bool) error {
+	parent, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+
+	if parent.parentId != 0 || parent.discordChannelId == channelId {
+		parent.mu.Unlock()
+		return ErrChannelTaken
+	}
+
+	settings := parent.settings()
+	store := parent.store
+	parent.mu.Unlock()
+
+	settings.GuildId = channelId
+	settings.ChannelId = channelId
+	settings.ParentId = guildId
+	settings.SeparateRanking = separateRanking
+	// teams are set up for the whole guild
+	settings.TeamsEnabled = false
+
+	gi := NewGameInstance(store, settings)
+	if instances.add(channelId, gi) != gi {
+		return ErrChannelTaken
+	}
+
+	err := store.AddGuild(channelId)
+	if err == nil {
+		err = store.SaveGuild(settings)
+	}
+
+	if err != nil {
+		instances.remove(channelId)
+		return err
+	}
+
+	disabled, err := store.DisabledCategories(guildId)
+	if err != nil {
+		log.Printf("copying categories of guild %d to channel %d: %v\n", guildId, channelId, err)
+		return nil
+	}
+
+	for _, category := range disabled {
+		err := store.SetCategoryEnabled(channelId, category, false)
+		if err != nil {
+			log.Printf("copying category %s of guild %d to channel %d: %v\n", category, guildId, channelId, err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveChannelGame stops an extra game of the guild. The stakes of
+// its current round are given back
+func RemoveChannelGame(guildId int, channelId int) error {
+	gi, ok := instances.get(channelId)
+	if !ok || gi.parentId != guildId {
+		return ErrNoChannelGame
+	}
+
+	gi.mu.Lock()
+	gi.stopTimer()
+	gi.stopHintTimers()
+	gi.settleBets(true)
+	// so an ad on its way is discarded
+	gi.prefetchVersion++
+	gi.round.open = false
+	thread := gi.round.threadId
+	gi.mu.Unlock()
+
+	if thread != 0 {
+		instances.dropThread(thread)
+	}
+
+	instances.remove(channelId)
+	return gi.store.RemoveGuild(channelId)
+}
+
+// ChannelGames are the extra games of the guild, sorted by channel
+func ChannelGames(guildId int) []ChannelGame {
+	var res []ChannelGame
+	for _, gi := range instances.all() {
+		if gi.parentId == guildId {
+			res = append(res, ChannelGame{ChannelId: gi.guildId, SeparateRanking: gi.separateRanking})
+		}
+	}
+
+	slices.SortFunc(res, func(a, b ChannelGame) int { return a.ChannelId - b.ChannelId })
+	return res
+}
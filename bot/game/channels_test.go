@@ -0,0 +1,198 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// waitForRanking waits for the scores that are saved in the background
+func waitForRanking(t *testing.T, gameId int, users int) []AggregatedScore {
+	t.Helper()
+
+	var ranking []AggregatedScore
+	eventually(t, func() bool {
+		var err error
+		ranking, err = Ranking(gameId)
+		if err != nil {
+			t.Fatalf("fetching ranking: %v\n", err)
+		}
+
+		return len(ranking) >= users
+	})
+
+	return ranking
+}
+
+func TestChannelGames(t *testing.T) {
+	guildId, mainChannel := 1, 10
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200, Category: "Esportes e Lazer"},
+		{Id: 2, Title: "Gol 1.0 2012", Price: 25000, Category: "Autos e peças"},
+	}, guildId)
+
+	err := SetChannel(guildId, mainChannel)
+	if err != nil {
+		t.Fatalf("setting channel: %v\n", err)
+	}
+
+	err = SetCategoryEnabled(guildId, "Autos e peças", false)
+	if err != nil {
+		t.Fatalf("disabling category: %v\n", err)
+	}
+
+	cars, general := 20, 30
+	for _, channelId := range []int{cars, general} {
+		err = AddChannelGame(guildId, channelId, channelId == cars)
+		if err != nil {
+			t.Fatalf("adding game in channel %d: %v\n", channelId, err)
+		}
+	}
+
+	for _, channelId := range []int{mainChannel, cars} {
+		err = AddChannelGame(guildId, channelId, false)
+		if err != ErrChannelTaken {
+			t.Fatalf("added a second game in channel %d\n  Want: %v\n  Got: %v\n", channelId, ErrChannelTaken, err)
+		}
+	}
+
+	err = SetChannel(guildId, cars)
+	if err != ErrChannelTaken {
+		t.Fatalf("main game moved to a channel with its own game\n  Want: %v\n  Got: %v\n", ErrChannelTaken, err)
+	}
+
+	expected := map[int]int{mainChannel: guildId, cars: cars, general: general}
+	for channelId, gameId := range expected {
+		got, ok := GameIn(guildId, channelId)
+		if !ok || got != gameId {
+			t.Fatalf("game in channel %d mismatch\n  Want: %d\n  Got: %d %v\n", channelId, gameId, got, ok)
+		}
+	}
+
+	if _, ok := GameIn(2, cars); ok {
+		t.Fatalf("found a game of guild 1 from guild 2\n")
+	}
+
+	// channels start with the filters of the main game, then change on their own
+	err = SetCategoryEnabled(cars, "Autos e peças", true)
+	if err != nil {
+		t.Fatalf("enabling category: %v\n", err)
+	}
+
+	err = SetCategoryEnabled(cars, "Esportes e Lazer", false)
+	if err != nil {
+		t.Fatalf("disabling category: %v\n", err)
+	}
+
+	for _, gameId := range []int{guildId, cars, general} {
+		err = StartRound(gameId)
+		if err != nil {
+			t.Fatalf("starting round of game %d: %v\n", gameId, err)
+		}
+	}
+
+	ads := map[int]int{guildId: 1, cars: 2, general: 1}
+	for gameId, adId := range ads {
+		if Ad(gameId).Id != adId {
+			t.Fatalf("ad of game %d mismatch\n  Want: %d\n  Got: %v\n", gameId, adId, Ad(gameId))
+		}
+	}
+
+	// scores of the general channel go to the guild, the cars channel keeps its own
+	CheckGuess(0, "ana", 1200, general)
+	CheckGuess(0, "bia", 25000, cars)
+
+	guildRanking := waitForRanking(t, guildId, 1)
+	carsRanking := waitForRanking(t, cars, 1)
+	if guildRanking[0].Username != "ana" || carsRanking[0].Username != "bia" || len(guildRanking) != 1 {
+		t.Fatalf("rankings mismatch\n  Got: %v %v\n", guildRanking, carsRanking)
+	}
+
+	err = RemoveChannelGame(guildId, cars)
+	if err != nil {
+		t.Fatalf("removing game: %v\n", err)
+	}
+
+	err = RemoveChannelGame(guildId, cars)
+	if err != ErrNoChannelGame {
+		t.Fatalf("removed game twice\n  Want: %v\n  Got: %v\n", ErrNoChannelGame, err)
+	}
+
+	if games := ChannelGames(guildId); !slices.Equal(games, []ChannelGame{{ChannelId: general}}) {
+		t.Fatalf("channel games mismatch\n  Want: %v\n  Got: %v\n", []ChannelGame{{ChannelId: general}}, games)
+	}
+
+	// games come back after a restart
+	err = LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("loading guilds: %v\n", err)
+	}
+
+	if gameId, ok := GameIn(guildId, general); !ok || gameId != general || Ad(general).Id != 1 {
+		t.Fatalf("channel game wasn't restored\n  Got: %d %v %v\n", gameId, ok, Ad(general))
+	}
+
+	if _, ok := GameIn(guildId, cars); ok {
+		t.Fatalf("removed game was restored\n")
+	}
+}
+
+func TestSQLStoreChannelGames(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId, channelId := 555261239926980456, 999
+
+	settings := defaultGuildSettings(channelId)
+	settings.ChannelId = channelId
+	settings.ParentId = guildId
+	settings.SeparateRanking = true
+
+	err := store.AddGuild(channelId)
+	if err == nil {
+		err = store.SaveGuild(settings)
+	}
+	if err != nil {
+		t.Fatalf("saving channel game: %v\n", err)
+	}
+
+	_, _, err = store.StartRound(channelId, 1, time.Now())
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	guilds, err := store.Guilds()
+	if err != nil {
+		t.Fatalf("fetching guilds: %v\n", err)
+	}
+
+	idx := slices.IndexFunc(guilds, func(g GuildSettings) bool { return g.GuildId == channelId })
+	if idx == -1 || guilds[idx].ParentId != guildId || !guilds[idx].SeparateRanking {
+		t.Fatalf("channel game wasn't saved\n  Got: %+v\n", guilds)
+	}
+
+	err = store.RemoveGuild(channelId)
+	if err != nil {
+		t.Fatalf("removing channel game: %v\n", err)
+	}
+
+	guilds, err = store.Guilds()
+	if err != nil {
+		t.Fatalf("fetching guilds: %v\n", err)
+	}
+
+	if slices.ContainsFunc(guilds, func(g GuildSettings) bool { return g.GuildId == channelId }) {
+		t.Fatalf("channel game wasn't removed\n")
+	}
+
+	rounds, err := store.CurrentRounds()
+	if err != nil {
+		t.Fatalf("fetching current rounds: %v\n", err)
+	}
+
+	if slices.ContainsFunc(rounds, func(r RoundState) bool { return r.GuildId == channelId }) {
+		t.Fatalf("round of removed channel game is still going\n")
+	}
+}
@@ -0,0 +1,233 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+	_ "time/tzdata"
+)
+
+// Bet is a guess with coins on it. Bets are paid when their round
+// ends, according to how close the guess was to the price
+type Bet struct {
+	Id       int
+	GuildId  int
+	RoundId  int
+	Username string
+	Guess    int
+	Stake    int
+	Payout   int
+	Settled  bool
+}
+
+type LedgerReason string
+
+const (
+	LedgerBet    LedgerReason = "aposta"
+	LedgerPayout LedgerReason = "premio"
+	LedgerRefund LedgerReason = "reembolso"
+	LedgerDaily  LedgerReason = "diaria"
+)
+
+// LedgerEntry is a change in the balance of a user. Amount is
+// negative for coins that left their wallet
+type LedgerEntry struct {
+	Amount    int
+	Reason    LedgerReason
+	BetId     int
+	CreatedAt time.Time
+}
+
+const DailyAllowance = 100
+
+var ErrNotEnoughCoins = errors.New("not enough coins")
+var ErrAlreadyBet = errors.New("user already bet in this round")
+var ErrGuessedBeforeBet = errors.New("user already guessed in this round")
+var ErrInvalidStake = errors.New("stake must be positive")
+var ErrAlreadyClaimed = errors.New("daily allowance already claimed")
+
+// days start at midnight in Brazil, no matter where the bot runs
+var saoPaulo = mustLoadLocation("America/Sao_Paulo")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// startOfDay is the midnight in São Paulo that started the day of t
+func startOfDay(t time.Time) time.Time {
+	t = t.In(saoPaulo)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, saoPaulo)
+}
+
+// bets pay a multiple of the stake according to how far off the guess was.
+// Brackets are sorted from the tightest to the loosest
+var payoutBrackets = []struct {
+	maxPercentDiff float64
+	multiplier     int
+}{
+	{3, 3},
+	{10, 2},
+	{25, 1},
+}
+
+const exactBetMultiplier = 5
+
+// Payout is how many coins a bet on guess gets back for an ad of the given price
+func Payout(guess int, price int, stake int) int {
+	if guess == price {
+		return stake * exactBetMultiplier
+	}
+
+	if isClose(guess, price) {
+		return stake * payoutBrackets[0].multiplier
+	}
+
+	diff := PercentDiff(guess, price)
+	for _, b := range payoutBrackets {
+		if diff <= b.maxPercentDiff {
+			return stake * b.multiplier
+		}
+	}
+
+	return 0
+}
+
+// PlaceBet stakes coins on a guess, which then goes through CheckGuess as usual.
+// Each user can bet once per round, and only on their first guess, so what
+// earlier guesses gave away can't be bet on
+func PlaceBet(userId int, user string, guess int, stake int, guildId int) (bool, error) {
+	if stake <= 0 {
+		return false, ErrInvalidStake
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false, ErrNoInstance
+	}
+
+	err := gi.placeBet(user, guess, stake)
+	if err != nil {
+		gi.mu.Unlock()
+		return false, err
+	}
+
+	isRight, happened, err := gi.checkGuess(userId, user, guess)
+	gi.mu.Unlock()
+
+	return finishGuess(guildId, isRight, happened, err)
+}
+
+func (gi *GameInstance) placeBet(user string, guess int, stake int) error {
+	if !gi.round.open {
+		return ErrRoundClosed
+	}
+
+	if gi.mode == ModePriceIsRight && gi.guessesLeft(user) == 0 {
+		return ErrNoGuessesLeft
+	}
+
+	if slices.ContainsFunc(gi.round.bets, func(b Bet) bool { return b.Username == user }) {
+		return ErrAlreadyBet
+	}
+
+	if slices.ContainsFunc(gi.round.guesses, func(g Guess) bool { return g.Username == user }) {
+		return ErrGuessedBeforeBet
+	}
+
+	bet, err := gi.store.PlaceBet(Bet{
+		GuildId:  gi.guild(),
+		RoundId:  gi.round.id,
+		Username: user,
+		Guess:    guess,
+		Stake:    stake,
+	})
+	if err != nil {
+		return err
+	}
+
+	gi.round.bets = append(gi.round.bets, bet)
+	return nil
+}
+
+// settleBets pays the bets of the current round, or gives the stakes back
+// if the round was skipped, and returns the bets that were settled
+func (gi *GameInstance) settleBets(refund bool) []Bet {
+	var settled []Bet
+	for idx, bet := range gi.round.bets {
+		if bet.Settled {
+			continue
+		}
+
+		if refund || gi.round.ad == nil {
+			bet.Payout = bet.Stake
+		} else {
+			bet.Payout = Payout(bet.Guess, gi.round.ad.Price, bet.Stake)
+		}
+		bet.Settled = true
+
+		gi.round.bets[idx] = bet
+		settled = append(settled, bet)
+	}
+
+	if len(settled) == 0 {
+		return nil
+	}
+
+	reason := LedgerPayout
+	if refund {
+		reason = LedgerRefund
+	}
+
+	// the ledger is written before the round's events go out, so balances
+	// are up to date by the time players hear about the payouts
+	err := gi.store.SettleBets(settled, reason)
+	if err != nil {
+		log.Printf("settling bets of round %d in guild %d: %v\n", gi.round.id, gi.guildId, err)
+	}
+
+	return settled
+}
+
+func Balance(guildId int, username string) (int, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return 0, ErrNoInstance
+	}
+
+	return gi.store.Balance(guildId, username)
+}
+
+// Ledger gets the latest changes in the balance of a user, from the most recent
+func Ledger(guildId int, username string, limit int) ([]LedgerEntry, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.Ledger(guildId, username, limit)
+}
+
+// ClaimDaily gives the user their DailyAllowance, once per day
+func ClaimDaily(guildId int, username string) error {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+
+	claimed, err := gi.store.ClaimDaily(guildId, username, DailyAllowance, startOfDay(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if !claimed {
+		return ErrAlreadyClaimed
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func expectBalance(t *testing.T, guildId int, username string, expected int) {
+	coins, err := Balance(guildId, username)
+	if err != nil {
+		t.Fatalf("fetching balance: %v\n", err)
+	}
+
+	if coins != expected {
+		t.Fatalf("balance of %s mismatch\n  Want: %d\n  Got: %d\n", username, expected, coins)
+	}
+}
+
+func TestPayout(t *testing.T) {
+	tests := []struct {
+		guess    int
+		expected int
+	}{
+		{1000, 50},
+		{1004, 30},
+		{1020, 30},
+		{1080, 20},
+		{1200, 10},
+		{2000, 0},
+	}
+
+	for _, test := range tests {
+		got := Payout(test.guess, 1000, 10)
+		if got != test.expected {
+			t.Fatalf("payout for R$ %d mismatch\n  Want: %d\n  Got: %d\n", test.guess, test.expected, got)
+		}
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	// 01:30 in UTC is still the day before in São Paulo
+	got := startOfDay(time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC))
+	expected := time.Date(2024, 3, 9, 3, 0, 0, 0, time.UTC)
+
+	if !got.Equal(expected) {
+		t.Fatalf("start of day mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+}
+
+func TestPlaceBet(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	err := ClaimDaily(guildId, "ana")
+	if err != nil {
+		t.Fatalf("claiming daily allowance: %v\n", err)
+	}
+
+	err = ClaimDaily(guildId, "ana")
+	if err != ErrAlreadyClaimed {
+		t.Fatalf("daily allowance was claimed twice\n  Want: %v\n  Got: %v\n", ErrAlreadyClaimed, err)
+	}
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	_, err = PlaceBet(0, "bia", 1200, 10, guildId)
+	if err != ErrNotEnoughCoins {
+		t.Fatalf("bet without coins was accepted\n  Want: %v\n  Got: %v\n", ErrNotEnoughCoins, err)
+	}
+
+	_, err = PlaceBet(0, "ana", 1100, 30, guildId)
+	if err != nil {
+		t.Fatalf("placing bet: %v\n", err)
+	}
+
+	_, err = PlaceBet(0, "ana", 1150, 30, guildId)
+	if err != ErrAlreadyBet {
+		t.Fatalf("second bet in the same round was accepted\n  Want: %v\n  Got: %v\n", ErrAlreadyBet, err)
+	}
+
+	expectBalance(t, guildId, "ana", 70)
+
+	var won RoundWon
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(RoundWon); ok && e.GuildId == guildId {
+			won = e
+		}
+	})
+	defer unsubscribe()
+
+	CheckGuess(0, "bia", 1200, guildId)
+
+	if len(won.Bets) != 1 || won.Bets[0].Payout != 60 {
+		t.Fatalf("bet wasn't paid when the round was won\n  Got: %+v\n", won.Bets)
+	}
+
+	expectBalance(t, guildId, "ana", 130)
+
+	// skipped rounds give the stakes back
+	_, err = PlaceBet(0, "ana", 1, 50, guildId)
+	if err != nil {
+		t.Fatalf("placing bet: %v\n", err)
+	}
+
+	expectBalance(t, guildId, "ana", 80)
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("skipping round: %v\n", err)
+	}
+
+	expectBalance(t, guildId, "ana", 130)
+
+	entries, err := Ledger(guildId, "ana", 10)
+	if err != nil {
+		t.Fatalf("fetching ledger: %v\n", err)
+	}
+
+	expected := []LedgerReason{LedgerRefund, LedgerBet, LedgerPayout, LedgerBet, LedgerDaily}
+	if len(entries) != len(expected) {
+		t.Fatalf("ledger mismatch\n  Want: %v\n  Got: %+v\n", expected, entries)
+	}
+
+	for idx, e := range entries {
+		if e.Reason != expected[idx] {
+			t.Fatalf("ledger entry #%d mismatch\n  Want: %v\n  Got: %v\n", idx+1, expected[idx], e.Reason)
+		}
+	}
+}
+
+func TestBetAfterGuess(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	err := ClaimDaily(guildId, "ana")
+	if err != nil {
+		t.Fatalf("claiming daily allowance: %v\n", err)
+	}
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	// a free guess tells ana she's close
+	_, err = CheckGuess(0, "ana", 1190, guildId)
+	if err != nil {
+		t.Fatalf("checking guess: %v\n", err)
+	}
+
+	_, err = PlaceBet(0, "ana", 1190, DailyAllowance, guildId)
+	if err != ErrGuessedBeforeBet {
+		t.Fatalf("bet after a guess was accepted\n  Want: %v\n  Got: %v\n", ErrGuessedBeforeBet, err)
+	}
+
+	expectBalance(t, guildId, "ana", DailyAllowance)
+}
+
+func TestSQLStoreCoins(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+	today := startOfDay(time.Now())
+
+	claimed, err := store.ClaimDaily(guildId, "ana", DailyAllowance, today)
+	if err != nil || !claimed {
+		t.Fatalf("claiming daily allowance: %v %v\n", claimed, err)
+	}
+
+	claimed, err = store.ClaimDaily(guildId, "ana", DailyAllowance, today)
+	if err != nil || claimed {
+		t.Fatalf("daily allowance was claimed twice: %v %v\n", claimed, err)
+	}
+
+	rounds, err := store.CurrentRounds()
+	if err != nil {
+		t.Fatalf("fetching current rounds: %v\n", err)
+	}
+
+	var roundId int
+	for _, r := range rounds {
+		if r.GuildId == guildId {
+			roundId = r.Id
+		}
+	}
+
+	if roundId == 0 {
+		t.Fatalf("guild %d has no open round\n", guildId)
+	}
+
+	_, err = store.PlaceBet(Bet{GuildId: guildId, RoundId: roundId, Username: "ana", Guess: 500, Stake: 150})
+	if err != ErrNotEnoughCoins {
+		t.Fatalf("bet over the balance was accepted\n  Want: %v\n  Got: %v\n", ErrNotEnoughCoins, err)
+	}
+
+	bet, err := store.PlaceBet(Bet{GuildId: guildId, RoundId: roundId, Username: "ana", Guess: 500, Stake: 40})
+	if err != nil {
+		t.Fatalf("placing bet: %v\n", err)
+	}
+
+	rounds, err = store.CurrentRounds()
+	if err != nil {
+		t.Fatalf("fetching current rounds: %v\n", err)
+	}
+
+	for _, r := range rounds {
+		if r.Id == roundId && (len(r.Bets) != 1 || r.Bets[0].Settled) {
+			t.Fatalf("open bet wasn't restored with its round\n  Got: %+v\n", r.Bets)
+		}
+	}
+
+	bet.Payout = 80
+	bet.Settled = true
+	err = store.SettleBets([]Bet{bet}, LedgerPayout)
+	if err != nil {
+		t.Fatalf("settling bet: %v\n", err)
+	}
+
+	coins, err := store.Balance(guildId, "ana")
+	if err != nil || coins != 140 {
+		t.Fatalf("balance mismatch\n  Want: %d\n  Got: %d %v\n", 140, coins, err)
+	}
+
+	entries, err := store.Ledger(guildId, "ana", 2)
+	if err != nil {
+		t.Fatalf("fetching ledger: %v\n", err)
+	}
+
+	expected := []LedgerEntry{
+		{Amount: 80, Reason: LedgerPayout, BetId: bet.Id},
+		{Amount: -40, Reason: LedgerBet, BetId: bet.Id},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("ledger mismatch\n  Want: %+v\n  Got: %+v\n", expected, entries)
+	}
+
+	for idx := range expected {
+		e := entries[idx]
+		if e.Amount != expected[idx].Amount || e.Reason != expected[idx].Reason || e.BetId != expected[idx].BetId {
+			t.Fatalf("ledger entry #%d mismatch\n  Want: %+v\n  Got: %+v\n", idx+1, expected[idx], e)
+		}
+	}
+}
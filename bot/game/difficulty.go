@@ -0,0 +1,99 @@
+package game
+
+import (
+	"errors"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// PriceRange limits the price of the ads drawn for a guild's rounds.
+// A Max of zero means there's no upper limit
+type PriceRange struct {
+	Min int
+	Max int
+}
+
+func (r PriceRange) Contains(price int) bool {
+	return price >= r.Min && (r.Max == 0 || price <= r.Max)
+}
+
+type Difficulty string
+
+const (
+	// DifficultyEasy sticks to everyday items, whose prices people have a feel for
+	DifficultyEasy   Difficulty = "facil"
+	DifficultyMedium Difficulty = "medio"
+	// DifficultyHard draws from every ad, from phone cases to trucks
+	DifficultyHard Difficulty = "dificil"
+	// DifficultyCustom is any range that isn't one of the presets
+	DifficultyCustom Difficulty = "personalizado"
+)
+
+// Difficulties are the presets guilds can pick from
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard}
+
+var difficultyRanges = map[Difficulty]PriceRange{
+	DifficultyEasy:   {Min: 10, Max: 2_000},
+	DifficultyMedium: {Min: 10, Max: 20_000},
+	DifficultyHard:   {},
+}
+
+var ErrUnknownDifficulty = errors.New("unknown difficulty")
+var ErrInvalidPriceRange = errors.New("invalid price range")
+
+// DifficultyRange is the price range of a preset
+func DifficultyRange(difficulty Difficulty) (PriceRange, bool) {
+	r, ok := difficultyRanges[difficulty]
+	return r, ok
+}
+
+func difficultyOf(r PriceRange) Difficulty {
+	for _, d := range Difficulties {
+		if difficultyRanges[d] == r {
+			return d
+		}
+	}
+
+	return DifficultyCustom
+}
+
+func GuildDifficulty(guildId int) (Difficulty, PriceRange) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return DifficultyHard, PriceRange{}
+	}
+	defer gi.mu.Unlock()
+
+	return difficultyOf(gi.priceRange), gi.priceRange
+}
+
+func SetDifficulty(guildId int, difficulty Difficulty) error {
+	r, ok := difficultyRanges[difficulty]
+	if !ok {
+		return ErrUnknownDifficulty
+	}
+
+	return SetPriceRange(guildId, r)
+}
+
+// SetPriceRange changes the prices of the ads drawn from the next round on
+func SetPriceRange(guildId int, r PriceRange) error {
+	if r.Min < 0 || r.Max < 0 || r.Max > olx.OLX_MAX_PRICE || (r.Max != 0 && r.Max < r.Min) {
+		return ErrInvalidPriceRange
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.priceRange = r
+	err := gi.saveSettings()
+	if err != nil {
+		return err
+	}
+
+	gi.dropPrefetched()
+	return nil
+}
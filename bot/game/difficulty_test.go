@@ -0,0 +1,81 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+)
+
+func TestPriceRangeContains(t *testing.T) {
+	tests := []struct {
+		Range    PriceRange
+		Price    int
+		Expected bool
+	}{
+		{PriceRange{}, 300000, true},
+		{PriceRange{Min: 10, Max: 2000}, 10, true},
+		{PriceRange{Min: 10, Max: 2000}, 2000, true},
+		{PriceRange{Min: 10, Max: 2000}, 9, false},
+		{PriceRange{Min: 10, Max: 2000}, 2001, false},
+		{PriceRange{Min: 500}, 300000, true},
+	}
+
+	for _, test := range tests {
+		if got := test.Range.Contains(test.Price); got != test.Expected {
+			t.Fatalf("range %v containing %d mismatch\n  Want: %v\n  Got: %v\n", test.Range, test.Price, test.Expected, got)
+		}
+	}
+}
+
+// prices are stored as text, which sorts 950 after 1200
+func TestRandomAdWithinPriceRange(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+
+	prices := PriceRange{Min: 1000, Max: 2000}
+	for range 20 {
+		ad, err := store.RandomAd(827261239926980668, prices)
+		if err != nil {
+			t.Fatalf("picking ad: %v\n", err)
+		}
+
+		if !prices.Contains(ad.Price) {
+			t.Fatalf("ad %d costs R$ %d, outside of %v\n", ad.Id, ad.Price, prices)
+		}
+	}
+}
+
+func TestSetDifficulty(t *testing.T) {
+	guildId := 555261239926980456
+	loadFixtureGuilds(t)
+
+	err := SetDifficulty(guildId, DifficultyEasy)
+	if err != nil {
+		t.Fatalf("setting difficulty: %v\n", err)
+	}
+
+	err = SetPriceRange(guildId, PriceRange{Min: 500, Max: 100})
+	if err != ErrInvalidPriceRange {
+		t.Fatalf("invalid range was accepted\n  Want: %v\n  Got: %v\n", ErrInvalidPriceRange, err)
+	}
+
+	// the difficulty survives a restart
+	err = LoadGuilds(NewSQLStore(db.Conn))
+	if err != nil {
+		t.Fatalf("reloading guilds: %v\n", err)
+	}
+
+	difficulty, prices := GuildDifficulty(guildId)
+	if difficulty != DifficultyEasy || prices != difficultyRanges[DifficultyEasy] {
+		t.Fatalf("difficulty mismatch\n  Want: %v\n  Got: %v %v\n", DifficultyEasy, difficulty, prices)
+	}
+
+	err = SetPriceRange(guildId, PriceRange{Min: 1000, Max: 1500})
+	if err != nil {
+		t.Fatalf("setting price range: %v\n", err)
+	}
+
+	if difficulty, _ := GuildDifficulty(guildId); difficulty != DifficultyCustom {
+		t.Fatalf("difficulty mismatch\n  Want: %v\n  Got: %v\n", DifficultyCustom, difficulty)
+	}
+}
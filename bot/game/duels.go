@@ -0,0 +1,418 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// how long the players of a duel have to guess
+const DuelTimeout = 2 * time.Minute
+
+// how many guesses each player gets in a duel
+const DuelGuesses = 3
+
+// how long the opponent has to accept a duel
+const DuelAcceptTimeout = time.Minute
+
+// how many times a duel draws an ad before giving up on finding one other
+// than the ad set aside for the guild's next round
+const duelAdTries = 20
+
+var ErrInDuel = errors.New("user is already in a duel")
+var ErrNoDuel = errors.New("user is not in a duel")
+var ErrSelfDuel = errors.New("users can't duel themselves")
+var ErrNoDuelGuessesLeft = errors.New("user has no guesses left in the duel")
+var ErrNoDuelChallenge = errors.New("user has no duel to accept")
+var ErrDuelNotAccepted = errors.New("duel wasn't accepted yet")
+
+// Duel is two users guessing the price of the same ad against the clock,
+// once the opponent accepts it. The closest guess wins
+type Duel struct {
+	Id         int
+	GuildId    int
+	ChannelId  int
+	Challenger string
+	Opponent   string
+	Ad         olx.OLXAd
+	// the guesses of each player, in the order they were made
+	ChallengerGuesses []int
+	OpponentGuesses   []int
+	StartedAt         time.Time
+	EndedAt           time.Time
+	// Winner is empty on draws
+	Winner string
+}
+
+// DuelFeedback is what players learn from each guess
+type DuelFeedback struct {
+	Exact       bool
+	Close       bool
+	GuessesLeft int
+}
+
+type DuelRecord struct {
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// DuelFinished is published when both players are out of guesses or the time runs out
+type DuelFinished struct {
+	Duel Duel
+	// CalledOff duels had a player who didn't guess, and count for nobody
+	CalledOff bool
+}
+
+func (e DuelFinished) Guild() int { return e.Duel.GuildId }
+
+// activeDuel is a duel that's still going, with the timer that ends it.
+// Until the opponent accepts it, the timer drops the challenge instead
+type activeDuel struct {
+	duel     Duel
+	accepted bool
+	// accepting is set while the ad of an accepted duel is picked and saved
+	accepting bool
+	timer     *time.Timer
+}
+
+// duelRegistry keeps the duels that are going on or waiting to be accepted.
+// Each user plays one duel at a time, and duels are finished by their timer
+// unless both players are done before
+type duelRegistry struct {
+	mu sync.Mutex
+	// players maps guild and username to the duel they're playing
+	players map[duelPlayer]*activeDuel
+}
+
+type duelPlayer struct {
+	guildId  int
+	username string
+}
+
+var duels = &duelRegistry{players: make(map[duelPlayer]*activeDuel)}
+
+// guessesOf gets the guesses of one of the players
+func (d *Duel) guessesOf(username string) *[]int {
+	if username == d.Challenger {
+		return &d.ChallengerGuesses
+	}
+
+	return &d.OpponentGuesses
+}
+
+// done tells whether the player can't guess anymore
+func (d *Duel) done(username string) bool {
+	guesses := *d.guessesOf(username)
+	return len(guesses) >= DuelGuesses || (len(guesses) > 0 && guesses[len(guesses)-1] == d.Ad.Price)
+}
+
+// BestDuelGuess is the guess closest to price, if there's any
+func BestDuelGuess(guesses []int, price int) (int, bool) {
+	if len(guesses) == 0 {
+		return 0, false
+	}
+
+	best := guesses[0]
+	for _, g := range guesses[1:] {
+		if distance(g, price) < distance(best, price) {
+			best = g
+		}
+	}
+
+	return best, true
+}
+
+// duelWinner is whoever got closer to the price. Players who didn't
+// guess lose, and it's a draw if both are just as close
+func duelWinner(d Duel) string {
+	challenger, challengerGuessed := BestDuelGuess(d.ChallengerGuesses, d.Ad.Price)
+	opponent, opponentGuessed := BestDuelGuess(d.OpponentGuesses, d.Ad.Price)
+
+	switch {
+	case !challengerGuessed && !opponentGuessed:
+		return ""
+	case !opponentGuessed:
+		return d.Challenger
+	case !challengerGuessed:
+		return d.Opponent
+	}
+
+	challengerDiff := distance(challenger, d.Ad.Price)
+	opponentDiff := distance(opponent, d.Ad.Price)
+
+	switch {
+	case challengerDiff < opponentDiff:
+		return d.Challenger
+	case opponentDiff < challengerDiff:
+		return d.Opponent
+	}
+
+	return ""
+}
+
+// StartDuel has challenger call opponent for a duel, which only starts
+// once they accept it. The duel is announced in channelId when it's over
+func StartDuel(guildId int, channelId int, challenger string, opponent string) (Duel, error) {
+	if challenger == opponent {
+		return Duel{}, ErrSelfDuel
+	}
+
+	if _, ok := instances.get(guildId); !ok {
+		return Duel{}, ErrNoInstance
+	}
+
+	duels.mu.Lock()
+	defer duels.mu.Unlock()
+
+	for _, username := range []string{challenger, opponent} {
+		if _, ok := duels.players[duelPlayer{guildId, username}]; ok {
+			return Duel{}, ErrInDuel
+		}
+	}
+
+	duel := Duel{
+		GuildId:    guildId,
+		ChannelId:  channelId,
+		Challenger: challenger,
+		Opponent:   opponent,
+	}
+
+	active := &activeDuel{duel: duel}
+	active.timer = time.AfterFunc(DuelAcceptTimeout, func() { duels.drop(active) })
+	duels.players[duelPlayer{guildId, challenger}] = active
+	duels.players[duelPlayer{guildId, opponent}] = active
+
+	return duel, nil
+}
+
+// AcceptDuel has the user accept the duel they were called for, picking its
+// ad with the guild's filters and starting the clock. The ad is picked and
+// saved without holding the registry, so other duels don't wait on the store
+func AcceptDuel(guildId int, username string) (Duel, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return Duel{}, ErrNoInstance
+	}
+
+	duels.mu.Lock()
+	active, ok := duels.players[duelPlayer{guildId, username}]
+	if !ok || active.accepted || active.accepting || active.duel.Opponent != username {
+		duels.mu.Unlock()
+		return Duel{}, ErrNoDuelChallenge
+	}
+	active.accepting = true
+	active.timer.Stop()
+	duel := active.duel
+	duels.mu.Unlock()
+
+	ad, err := gi.duelAd()
+	if err == nil {
+		duel.Ad = ad
+		duel.StartedAt = time.Now()
+		duel.Id, err = gi.store.StartDuel(duel)
+	}
+	if err != nil {
+		duels.mu.Lock()
+		active.accepting = false
+		duels.mu.Unlock()
+		duels.drop(active)
+		return Duel{}, err
+	}
+
+	// a prefetch that was on its way might have drawn the duel's ad before
+	// it was saved as seen
+	gi.mu.Lock()
+	if gi.prefetching || (gi.nextAd != nil && gi.nextAd.Id == duel.Ad.Id) {
+		gi.dropPrefetched()
+	}
+	gi.mu.Unlock()
+
+	duels.mu.Lock()
+	defer duels.mu.Unlock()
+
+	if duels.players[duelPlayer{guildId, username}] != active {
+		return Duel{}, ErrNoDuelChallenge
+	}
+
+	active.duel = duel
+	active.accepting = false
+	active.accepted = true
+	active.timer = time.AfterFunc(DuelTimeout, func() { duels.finish(gi.store, active) })
+
+	return duel, nil
+}
+
+// duelAd picks the ad of a duel in the guild, leaving out the one set aside
+// for its next round
+func (gi *GameInstance) duelAd() (olx.OLXAd, error) {
+	gi.mu.Lock()
+	prices := gi.priceRange
+	reserved := 0
+	if gi.nextAd != nil {
+		reserved = gi.nextAd.Id
+	}
+	gi.mu.Unlock()
+
+	var ad olx.OLXAd
+	var err error
+	for range duelAdTries {
+		ad, err = gi.store.RandomAd(gi.guildId, prices)
+		if err != nil {
+			return olx.OLXAd{}, err
+		}
+
+		if ad.Id != reserved {
+			break
+		}
+	}
+
+	if ad.Id == reserved {
+		return olx.OLXAd{}, ErrNoAds
+	}
+
+	return ad, nil
+}
+
+// drop frees the players of a duel that wasn't accepted in time. The
+// timer might go off while the duel is being accepted, which wins
+func (r *duelRegistry) drop(active *activeDuel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	duel := active.duel
+	key := duelPlayer{duel.GuildId, duel.Challenger}
+	if r.players[key] != active || active.accepted || active.accepting {
+		return
+	}
+
+	delete(r.players, key)
+	delete(r.players, duelPlayer{duel.GuildId, duel.Opponent})
+}
+
+// reset drops every duel without finishing them
+func (r *duelRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, active := range r.players {
+		active.timer.Stop()
+	}
+	r.players = make(map[duelPlayer]*activeDuel)
+}
+
+// finish ends the duel unless it's over already, saving and announcing the
+// result. Duels a player didn't guess in are called off instead
+func (r *duelRegistry) finish(store Store, active *activeDuel) {
+	r.mu.Lock()
+	duel := active.duel
+	key := duelPlayer{duel.GuildId, duel.Challenger}
+	if r.players[key] != active {
+		r.mu.Unlock()
+		return
+	}
+
+	active.timer.Stop()
+	delete(r.players, key)
+	delete(r.players, duelPlayer{duel.GuildId, duel.Opponent})
+
+	duel.EndedAt = time.Now()
+	calledOff := len(duel.ChallengerGuesses) == 0 || len(duel.OpponentGuesses) == 0
+	if !calledOff {
+		duel.Winner = duelWinner(duel)
+	}
+	r.mu.Unlock()
+
+	// called off duels are left unfinished in the store, so they aren't counted
+	if !calledOff {
+		err := store.FinishDuel(duel)
+		if err != nil {
+			log.Printf("saving duel %d of guild %d: %v\n", duel.Id, duel.GuildId, err)
+		}
+	}
+
+	bus.Publish(DuelFinished{Duel: duel, CalledOff: calledOff})
+}
+
+// CurrentDuel gets the duel the user is playing
+func CurrentDuel(guildId int, username string) (Duel, error) {
+	duels.mu.Lock()
+	defer duels.mu.Unlock()
+
+	active, ok := duels.players[duelPlayer{guildId, username}]
+	if !ok {
+		return Duel{}, ErrNoDuel
+	}
+
+	if !active.accepted {
+		return Duel{}, ErrDuelNotAccepted
+	}
+
+	duel := active.duel
+	duel.ChallengerGuesses = slices.Clone(duel.ChallengerGuesses)
+	duel.OpponentGuesses = slices.Clone(duel.OpponentGuesses)
+
+	return duel, nil
+}
+
+// DuelGuess is a guess in the user's current duel. The duel ends
+// right away once both players are done guessing
+func DuelGuess(guildId int, username string, guess int) (DuelFeedback, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return DuelFeedback{}, ErrNoInstance
+	}
+
+	duels.mu.Lock()
+	active, ok := duels.players[duelPlayer{guildId, username}]
+	if !ok {
+		duels.mu.Unlock()
+		return DuelFeedback{}, ErrNoDuel
+	}
+
+	if !active.accepted {
+		duels.mu.Unlock()
+		return DuelFeedback{}, ErrDuelNotAccepted
+	}
+
+	d := &active.duel
+	if d.done(username) {
+		duels.mu.Unlock()
+		return DuelFeedback{}, ErrNoDuelGuessesLeft
+	}
+
+	guesses := d.guessesOf(username)
+	*guesses = append(*guesses, guess)
+
+	feedback := DuelFeedback{
+		Exact:       guess == d.Ad.Price,
+		Close:       isClose(guess, d.Ad.Price),
+		GuessesLeft: DuelGuesses - len(*guesses),
+	}
+	if feedback.Exact {
+		feedback.GuessesLeft = 0
+	}
+
+	over := d.done(d.Challenger) && d.done(d.Opponent)
+	duels.mu.Unlock()
+
+	if over {
+		duels.finish(gi.store, active)
+	}
+
+	return feedback, nil
+}
+
+// DuelRecordOf counts the duels the user won, lost and drew in the guild
+func DuelRecordOf(guildId int, username string) (DuelRecord, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return DuelRecord{}, ErrNoInstance
+	}
+
+	return gi.store.DuelRecord(guildId, username)
+}
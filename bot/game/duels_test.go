@@ -0,0 +1,351 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestDuelWinner(t *testing.T) {
+	type TestMatch struct {
+		Name       string
+		Challenger []int
+		Opponent   []int
+		Expected   string
+	}
+
+	matches := []TestMatch{
+		{"closest guess wins", []int{500, 950}, []int{1100}, "ana"},
+		{"only the best guess counts", []int{1300}, []int{100, 1020}, "bia"},
+		{"same guess is a draw", []int{900}, []int{700, 900}, ""},
+		{"nobody guessed", nil, nil, ""},
+		{"who didn't guess loses", nil, []int{5000}, "bia"},
+	}
+
+	for _, m := range matches {
+		d := Duel{
+			Challenger:        "ana",
+			Opponent:          "bia",
+			Ad:                olx.OLXAd{Price: 1000},
+			ChallengerGuesses: m.Challenger,
+			OpponentGuesses:   m.Opponent,
+		}
+
+		got := duelWinner(d)
+		if got != m.Expected {
+			t.Fatalf("%s: winner mismatch\n  Want: %q\n  Got: %q\n", m.Name, m.Expected, got)
+		}
+	}
+}
+
+func TestDuel(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	_, err := StartDuel(guildId, 10, "ana", "ana")
+	if err != ErrSelfDuel {
+		t.Fatalf("user dueled themselves\n  Want: %v\n  Got: %v\n", ErrSelfDuel, err)
+	}
+
+	_, err = StartDuel(guildId, 10, "ana", "bia")
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	_, err = StartDuel(guildId, 10, "caio", "bia")
+	if err != ErrInDuel {
+		t.Fatalf("user joined two duels\n  Want: %v\n  Got: %v\n", ErrInDuel, err)
+	}
+
+	_, err = DuelGuess(guildId, "ana", 1000)
+	if err != ErrDuelNotAccepted {
+		t.Fatalf("guess before the duel was accepted was counted\n  Want: %v\n  Got: %v\n", ErrDuelNotAccepted, err)
+	}
+
+	_, err = AcceptDuel(guildId, "ana")
+	if err != ErrNoDuelChallenge {
+		t.Fatalf("challenger accepted their own duel\n  Want: %v\n  Got: %v\n", ErrNoDuelChallenge, err)
+	}
+
+	accepted, err := AcceptDuel(guildId, "bia")
+	if err != nil {
+		t.Fatalf("accepting duel: %v\n", err)
+	}
+
+	if accepted.Ad.Id != 1 || accepted.StartedAt.IsZero() {
+		t.Fatalf("accepted duel has no ad nor start: %v\n", accepted)
+	}
+
+	_, err = AcceptDuel(guildId, "bia")
+	if err != ErrNoDuelChallenge {
+		t.Fatalf("duel was accepted twice\n  Want: %v\n  Got: %v\n", ErrNoDuelChallenge, err)
+	}
+
+	_, err = DuelGuess(guildId, "caio", 1000)
+	if err != ErrNoDuel {
+		t.Fatalf("guess out of a duel was accepted\n  Want: %v\n  Got: %v\n", ErrNoDuel, err)
+	}
+
+	finished := make(chan Duel, 1)
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(DuelFinished); ok && e.Duel.GuildId == guildId {
+			finished <- e.Duel
+		}
+	})
+	defer unsubscribe()
+
+	for _, guess := range []int{500, 800, 900} {
+		_, err = DuelGuess(guildId, "ana", guess)
+		if err != nil {
+			t.Fatalf("guessing in duel: %v\n", err)
+		}
+	}
+
+	_, err = DuelGuess(guildId, "ana", 1200)
+	if err != ErrNoDuelGuessesLeft {
+		t.Fatalf("guess past the limit was accepted\n  Want: %v\n  Got: %v\n", ErrNoDuelGuessesLeft, err)
+	}
+
+	duel, err := CurrentDuel(guildId, "bia")
+	if err != nil || len(duel.ChallengerGuesses) != DuelGuesses {
+		t.Fatalf("duel doesn't have the challenger's guesses: %v %v\n", duel, err)
+	}
+
+	feedback, err := DuelGuess(guildId, "bia", 1200)
+	if err != nil {
+		t.Fatalf("guessing in duel: %v\n", err)
+	}
+
+	expected := DuelFeedback{Exact: true, Close: true}
+	if feedback != expected {
+		t.Fatalf("feedback mismatch\n  Want: %v\n  Got: %v\n", expected, feedback)
+	}
+
+	select {
+	case duel = <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("duel didn't finish when both players were done\n")
+	}
+
+	if duel.Winner != "bia" {
+		t.Fatalf("duel winner mismatch\n  Want: bia\n  Got: %s\n", duel.Winner)
+	}
+
+	_, err = CurrentDuel(guildId, "ana")
+	if err != ErrNoDuel {
+		t.Fatalf("finished duel is still going\n  Want: %v\n  Got: %v\n", ErrNoDuel, err)
+	}
+
+	record, err := store.DuelRecord(guildId, "ana")
+	if err != nil {
+		t.Fatalf("fetching duel record: %v\n", err)
+	}
+
+	if record != (DuelRecord{Losses: 1}) {
+		t.Fatalf("duel record mismatch\n  Want: %v\n  Got: %v\n", DuelRecord{Losses: 1}, record)
+	}
+}
+
+func TestDuelChallengeExpires(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	_, err := StartDuel(guildId, 10, "ana", "bia")
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	duels.mu.Lock()
+	active := duels.players[duelPlayer{guildId, "bia"}]
+	duels.mu.Unlock()
+	duels.drop(active)
+
+	_, err = AcceptDuel(guildId, "bia")
+	if err != ErrNoDuelChallenge {
+		t.Fatalf("expired duel was accepted\n  Want: %v\n  Got: %v\n", ErrNoDuelChallenge, err)
+	}
+
+	_, err = StartDuel(guildId, 10, "bia", "ana")
+	if err != nil {
+		t.Fatalf("players are still busy after the challenge expired: %v\n", err)
+	}
+}
+
+func TestDuelSkipsPrefetchedAd(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+		{Id: 2, Title: "iPhone XR 64Gb - Preto", Price: 850},
+		{Id: 3, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	err := NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	next := waitForPrefetch(t, guildId)
+	if next == nil {
+		t.Fatalf("no ad was prefetched\n")
+	}
+
+	_, err = StartDuel(guildId, 10, "ana", "bia")
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	duel, err := AcceptDuel(guildId, "bia")
+	if err != nil {
+		t.Fatalf("accepting duel: %v\n", err)
+	}
+
+	if duel.Ad.Id == next.Id || duel.Ad.Id == Ad(guildId).Id {
+		t.Fatalf("duel got an ad of the guild's rounds: %d\n", duel.Ad.Id)
+	}
+
+	// the only ad left is the one set aside for the next round
+	_, err = StartDuel(guildId, 10, "caio", "duda")
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	_, err = AcceptDuel(guildId, "duda")
+	if err != ErrNoAds {
+		t.Fatalf("duel took the prefetched ad\n  Want: %v\n  Got: %v\n", ErrNoAds, err)
+	}
+
+	// and the challenge that couldn't start frees its players
+	_, err = StartDuel(guildId, 10, "duda", "caio")
+	if err != nil {
+		t.Fatalf("players are still busy after the duel failed to start: %v\n", err)
+	}
+
+	err = NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	if id := Ad(guildId).Id; id != next.Id {
+		t.Fatalf("round didn't use the prefetched ad\n  Want: %d\n  Got: %d\n", next.Id, id)
+	}
+}
+
+func TestDuelCalledOff(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	_, err := StartDuel(guildId, 10, "ana", "bia")
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	_, err = AcceptDuel(guildId, "bia")
+	if err != nil {
+		t.Fatalf("accepting duel: %v\n", err)
+	}
+
+	_, err = DuelGuess(guildId, "ana", 1200)
+	if err != nil {
+		t.Fatalf("guessing in duel: %v\n", err)
+	}
+
+	finished := make(chan DuelFinished, 1)
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(DuelFinished); ok && e.Duel.GuildId == guildId {
+			finished <- e
+		}
+	})
+	defer unsubscribe()
+
+	// the time runs out without bia guessing
+	duels.mu.Lock()
+	active := duels.players[duelPlayer{guildId, "bia"}]
+	duels.mu.Unlock()
+	duels.finish(store, active)
+
+	e := <-finished
+	if !e.CalledOff || e.Duel.Winner != "" {
+		t.Fatalf("duel without bia's guesses wasn't called off: %v\n", e)
+	}
+
+	record, err := store.DuelRecord(guildId, "ana")
+	if err != nil {
+		t.Fatalf("fetching duel record: %v\n", err)
+	}
+
+	if record != (DuelRecord{}) {
+		t.Fatalf("called off duel was counted\n  Want: %v\n  Got: %v\n", DuelRecord{}, record)
+	}
+}
+
+func TestSQLStoreDuels(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	ad, err := store.RandomAd(guildId, PriceRange{})
+	if err != nil {
+		t.Fatalf("fetching ad: %v\n", err)
+	}
+
+	results := []string{"ana", "bia", ""}
+	for _, winner := range results {
+		duel := Duel{
+			GuildId:    guildId,
+			Challenger: "ana",
+			Opponent:   "bia",
+			Ad:         ad,
+			StartedAt:  time.Now(),
+		}
+
+		duel.Id, err = store.StartDuel(duel)
+		if err != nil {
+			t.Fatalf("starting duel: %v\n", err)
+		}
+
+		duel.ChallengerGuesses = []int{ad.Price}
+		duel.EndedAt = time.Now()
+		duel.Winner = winner
+		err = store.FinishDuel(duel)
+		if err != nil {
+			t.Fatalf("finishing duel: %v\n", err)
+		}
+	}
+
+	// a duel that never finished counts for nobody
+	_, err = store.StartDuel(Duel{GuildId: guildId, Challenger: "ana", Opponent: "bia", Ad: ad, StartedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("starting duel: %v\n", err)
+	}
+
+	record, err := store.DuelRecord(guildId, "bia")
+	if err != nil {
+		t.Fatalf("fetching duel record: %v\n", err)
+	}
+
+	expected := DuelRecord{Wins: 1, Losses: 1, Draws: 1}
+	if record != expected {
+		t.Fatalf("duel record mismatch\n  Want: %v\n  Got: %v\n", expected, record)
+	}
+
+	// the guild's rounds don't get the ad the duelists know the price of
+	var seen int
+	err = db.Conn.QueryRow(`
+		SELECT COUNT(*) FROM seen_ads WHERE guild_id = ? AND ad_id = ?
+	`, guildId, ad.Id).Scan(&seen)
+	if err != nil {
+		t.Fatalf("fetching seen ads: %v\n", err)
+	}
+
+	if seen != 1 {
+		t.Fatalf("duel ad wasn't seen by the guild\n  Want: %d\n  Got: %d\n", 1, seen)
+	}
+}
@@ -0,0 +1,127 @@
+package game
+
+import (
+	"sync"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// Event is something that happened in the game of a guild
+type Event interface {
+	Guild() int
+}
+
+// RoundStarted is published right before the round opens for guesses
+type RoundStarted struct {
+	GuildId int
+	Number  int
+	Ad      olx.OLXAd
+}
+
+type GuessReceived struct {
+	GuildId    int
+	Username   string
+	Value      int
+	GuessCount int
+}
+
+// Thread is the thread of the round, 0 if it has none. It's
+// the same for every event that happens during a round
+type HintUnlocked struct {
+	GuildId int
+	Thread  int
+	Hint    Hint
+}
+
+// RoundWon is published once the round is closed and scored. Guess is the
+// winning guess, which might not be the exact price in guilds with a win
+// tolerance. Team is the winner's team, if the guild plays in teams, and
+// Standings are only filled in price is right rounds
+type RoundWon struct {
+	GuildId   int
+	Thread    int
+	Winner    string
+	Guess     int
+	Team      string
+	Ad        olx.OLXAd
+	Scores    []RoundScore
+	Bets      []Bet
+	Standings []Standing
+}
+
+// RoundExpired is published once a round that ran out of time is closed
+// and scored. Closest is the closest guess of classic rounds, if any, while
+// Winner and Standings are only filled in price is right rounds
+type RoundExpired struct {
+	GuildId   int
+	Thread    int
+	Ad        olx.OLXAd
+	Closest   *Guess
+	Winner    string
+	Team      string
+	Scores    []RoundScore
+	Bets      []Bet
+	Standings []Standing
+}
+
+func (e RoundStarted) Guild() int  { return e.GuildId }
+func (e GuessReceived) Guild() int { return e.GuildId }
+func (e HintUnlocked) Guild() int  { return e.GuildId }
+func (e RoundWon) Guild() int      { return e.GuildId }
+func (e RoundExpired) Guild() int  { return e.GuildId }
+
+type subscriber struct {
+	id int
+	f  func(Event)
+}
+
+// Bus delivers events to every subscriber, in the order they subscribed.
+// Subscribers are called synchronously and never while a guild's instance
+// is locked, so they're free to call back into the game
+type Bus struct {
+	mu          sync.RWMutex
+	nextId      int
+	subscribers []subscriber
+}
+
+// Subscribe registers f to receive every event published from now on.
+// Calling the returned function stops the deliveries
+func (b *Bus) Subscribe(f func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextId++
+	id := b.nextId
+	b.subscribers = append(b.subscribers, subscriber{id: id, f: f})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, s := range b.subscribers {
+			if s.id == id {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (b *Bus) Publish(events ...Event) {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, e := range events {
+		for _, s := range subscribers {
+			s.f(e)
+		}
+	}
+}
+
+var bus = &Bus{}
+
+// Subscribe registers f to receive the events of every guild
+func Subscribe(f func(Event)) func() {
+	return bus.Subscribe(f)
+}
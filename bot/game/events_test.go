@@ -0,0 +1,27 @@
+package game
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	var b Bus
+	var got []string
+
+	b.Subscribe(func(e Event) {
+		got = append(got, "first")
+	})
+	unsubscribe := b.Subscribe(func(e Event) {
+		got = append(got, "second")
+	})
+
+	b.Publish(RoundStarted{GuildId: 1})
+	unsubscribe()
+	b.Publish(RoundStarted{GuildId: 1})
+
+	expected := []string{"first", "second", "first"}
+	if !slices.Equal(got, expected) {
+		t.Fatalf("deliveries mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+}
@@ -1,24 +1,25 @@
 package game
 
 import (
-	"context"
-	"database/sql"
 	"errors"
-	"fmt"
 	"log"
 	"math"
-	"strings"
+	"slices"
 	"sync"
+	"time"
 
-	"github.com/gabrieleiro/olx-bets/bot/db"
 	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
 
 type Guess struct {
 	Id       int
 	GuildId  int
+	RoundId  int
 	Value    int
 	Username string
+	// UserId is the discord id of the user, 0 for guesses made before it was kept
+	UserId    int
+	CreatedAt time.Time
 }
 
 type ClosestGuessHint struct {
@@ -27,359 +28,725 @@ type ClosestGuessHint struct {
 }
 
 type Round struct {
-	guessCount   int
-	ad           *olx.OLXAd
-	open         bool
+	id         int
+	number     int
+	guessCount int
+	guesses    []Guess
+	ad         *olx.OLXAd
+	open       bool
+	// announcing is set while a round started by this process waits to
+	// open. Restored rounds that never opened were cut short by a restart
+	announcing   bool
+	hints        []string
 	samePrice    []int
+	startedAt    time.Time
+	endedAt      time.Time
+	endReason    EndReason
+	winner       string
+	bets         []Bet
+	threadId     int
 	ClosestGuess *ClosestGuessHint
 }
 
+// GameInstance is the game of a single guild, or one of the extra games
+// of a guild, in which case guildId is the id of its channel (see channels.go).
+// Every field but store, guildId, parentId and separateRanking is guarded
+// by mu. Those never change
 type GameInstance struct {
 	mu               sync.Mutex
+	store            Store
+	guildId          int
+	parentId         int
+	separateRanking  bool
 	discordChannelId int
-	round            Round
+	roundTimeout     time.Duration
+	mode             Mode
+	maxGuesses       int
+	priceRange       PriceRange
+	tolerance        Tolerance
+	// skipVotes is how many votes skipping a round takes
+	skipVotes    int
+	skipVote     *skipVote
+	lastSkip     time.Time
+	teamsEnabled bool
+	// teams and memberTeams cache what JoinTeamByRoles and the wins need
+	// to know, so guesses don't hit the store. teams is nil until it's
+	// loaded, and memberTeams maps users to the name of their team, empty
+	// for users without one
+	teams        []Team
+	memberTeams  map[string]string
+	roundThreads bool
+	hintRules    []HintRule
+	timer        *time.Timer
+	hintTimers   []*time.Timer
+	// nextAd is the prefetched ad of the next round, if it's ready.
+	// prefetchVersion changes whenever ads on their way become stale
+	nextAd          *olx.OLXAd
+	prefetching     bool
+	prefetchVersion int
+	round           Round
 }
 
-// we use guild ids as keys for this map
-var instances map[int]*GameInstance
+var instances = newRegistry(nil)
+
+var ErrNoInstance = errors.New("guild has no game instance")
+var ErrNoStore = errors.New("guilds haven't been loaded yet")
+
+// NewGameInstance creates the game of a guild, persisting it with store.
+// The instance starts without a round, call StartRound to get one going
+func NewGameInstance(store Store, settings GuildSettings) *GameInstance {
+	return &GameInstance{
+		store:            store,
+		guildId:          settings.GuildId,
+		parentId:         settings.ParentId,
+		separateRanking:  settings.SeparateRanking,
+		discordChannelId: settings.ChannelId,
+		roundTimeout:     settings.RoundTimeout,
+		mode:             settings.Mode,
+		maxGuesses:       settings.MaxGuesses,
+		priceRange:       settings.PriceRange,
+		tolerance:        settings.Tolerance,
+		skipVotes:        settings.SkipVotes,
+		teamsEnabled:     settings.TeamsEnabled,
+		roundThreads:     settings.RoundThreads,
+		hintRules:        settings.HintRules,
+	}
+}
+
+func (gi *GameInstance) settings() GuildSettings {
+	return GuildSettings{
+		GuildId:         gi.guildId,
+		ChannelId:       gi.discordChannelId,
+		RoundTimeout:    gi.roundTimeout,
+		Mode:            gi.mode,
+		MaxGuesses:      gi.maxGuesses,
+		PriceRange:      gi.priceRange,
+		Tolerance:       gi.tolerance,
+		SkipVotes:       gi.skipVotes,
+		TeamsEnabled:    gi.teamsEnabled,
+		ParentId:        gi.parentId,
+		SeparateRanking: gi.separateRanking,
+		RoundThreads:    gi.roundThreads,
+		HintRules:       slices.Clone(gi.hintRules),
+	}
+}
+
+func (gi *GameInstance) saveSettings() error {
+	return gi.store.SaveGuild(gi.settings())
+}
+
+// lockInstance returns the guild's instance with its lock held.
+// Callers must unlock it when they're done
+func lockInstance(guildId int) (*GameInstance, bool) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, false
+	}
+
+	gi.mu.Lock()
+	return gi, true
+}
+
+func (gi *GameInstance) incrementGuessCount(guess int, userId int, user string) {
+	g := Guess{
+		GuildId:   gi.guildId,
+		RoundId:   gi.round.id,
+		Value:     guess,
+		Username:  user,
+		UserId:    userId,
+		CreatedAt: time.Now(),
+	}
 
-func (gi *GameInstance) incrementGuessCount(guildId int, guess int, user string) error {
 	go func() {
-		_, err := db.Conn.Exec(`
-		INSERT INTO guesses(guild_id, value, username)
-		VALUES (?, ?, ?)`,
-			guildId, guess, user)
+		err := gi.store.AddGuess(g)
 		if err != nil {
-			log.Printf("registering guess %d from user %s in guild %d: %v\n", guess, user, guildId, err)
+			log.Printf("registering guess %d from user %s in guild %d: %v\n", guess, user, gi.guildId, err)
 		}
 	}()
 
 	gi.round.guessCount += 1
-	return nil
+	gi.round.guesses = append(gi.round.guesses, g)
 }
 
-func ClosestGuess(guildId int) (Guess, error) {
-	res := Guess{}
+var ErrNoGuesses = errors.New("no guesses in this round")
 
-	ad := instances[guildId].round.ad
-	row := db.Conn.QueryRow(`
-		SELECT id, guild_id, value, username
-		FROM (
-			SELECT *, ABS(value-?) AS diff
-			FROM guesses
-			WHERE guild_id = ?
-		)
-		ORDER BY diff
-		LIMIT 1`, ad.Price, guildId)
+// PercentDiff is how far off guess is from price, relative to the mean of both
+func PercentDiff(guess int, price int) float64 {
+	mean := (float64(guess) + float64(price)) / 2
+	if mean == 0 {
+		return 0
+	}
 
-	err := row.Scan(&res.Id, &res.GuildId, &res.Value, &res.Username)
+	diff := math.Abs(float64(guess) - float64(price))
+	return (diff / mean) * 100
+}
 
-	instances[guildId].round.ClosestGuess = &ClosestGuessHint{
-		Username: res.Username,
-		Guess:    res.Value,
+// distance is how many reais guess is off from price. It's what ranks
+// guesses, so guesses over the price aren't favored over the ones under it
+func distance(guess int, price int) int {
+	if guess > price {
+		return guess - price
 	}
 
-	return res, err
+	return price - guess
+}
+
+func isClose(guess int, price int) bool {
+	diff := math.Abs(float64(guess) - float64(price))
+	return diff <= 5 || PercentDiff(guess, price) <= 3
 }
 
 func IsClose(guess int, guildId int) (bool, error) {
-	ad := instances[guildId].round.ad
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false, ErrNoInstance
+	}
+	defer gi.mu.Unlock()
 
-	mean := (float64(guess) + float64(ad.Price)) / 2
-	diff := math.Abs(float64(guess) - float64(ad.Price))
-	percentDiff := (diff / mean) * 100
+	ad := gi.round.ad
+	if ad == nil {
+		return false, nil
+	}
 
-	return diff <= 5 || percentDiff <= 3, nil
+	return isClose(guess, ad.Price), nil
 }
 
 func IsWayOff(guess int, guildId int) bool {
-	ad := instances[guildId].round.ad
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false
+	}
+	defer gi.mu.Unlock()
+
+	ad := gi.round.ad
+	if ad == nil {
+		return false
+	}
+
 	return (guess >= (ad.Price * 3)) || guess <= (ad.Price/3)
 }
 
 var ErrRoundClosed = errors.New("round is closed")
+var ErrNoAd = errors.New("round has no ad")
+
+// CheckGuess registers a guess in the current round. Once the guess is in,
+// subscribers hear about it along with any hints it unlocked. A right guess
+// closes and scores the round, and the next one starts right away
+func CheckGuess(userId int, user string, guess int, guildId int) (bool, error) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false, ErrNoInstance
+	}
 
-func CheckGuess(user string, guess int, guildId int) (bool, error) {
-	gi := instances[guildId]
+	isRight, happened, err := gi.checkGuess(userId, user, guess)
+	gi.mu.Unlock()
 
-	gi.mu.Lock()
-	defer gi.mu.Unlock()
+	return finishGuess(guildId, isRight, happened, err)
+}
+
+// finishGuess publishes what a guess caused, once the instance is unlocked
+func finishGuess(guildId int, isRight bool, happened []Event, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+
+	bus.Publish(happened...)
+
+	if isRight {
+		err = StartRound(guildId)
+		if err != nil {
+			log.Printf("starting round after a win in guild %d: %v\n", guildId, err)
+		}
+	}
 
-	gi.incrementGuessCount(guildId, guess, user)
+	return isRight, nil
+}
+
+func (gi *GameInstance) checkGuess(userId int, user string, guess int) (bool, []Event, error) {
+	if gi.mode == ModePriceIsRight && gi.round.open && gi.guessesLeft(user) == 0 {
+		return false, nil, ErrNoGuessesLeft
+	}
 
 	if !gi.round.open {
-		return false, ErrRoundClosed
+		return false, nil, ErrRoundClosed
 	}
 
+	gi.incrementGuessCount(guess, userId, user)
+
+	happened := []Event{GuessReceived{
+		GuildId:    gi.guildId,
+		Username:   user,
+		Value:      guess,
+		GuessCount: gi.round.guessCount,
+	}}
+
 	ad := gi.round.ad
 
 	if ad == nil {
-		log.Printf("guess %d without an ad in guild %d", guess, guildId)
-		return false, nil
+		log.Printf("guess %d without an ad in guild %d", guess, gi.guildId)
+		return false, happened, nil
 	}
 
-	if guess == ad.Price {
-		closeRound(guildId)
-		return true, nil
-	}
+	if gi.wins(guess, ad.Price) {
+		won := RoundWon{
+			GuildId: gi.guildId,
+			Thread:  gi.round.threadId,
+			Winner:  user,
+			Guess:   guess,
+			Team:    gi.teamName(user),
+			Ad:      *ad,
+		}
+		if gi.mode == ModePriceIsRight {
+			won.Standings = standings(gi.round.guesses, ad.Price)
+		}
 
-	return false, nil
-}
+		gi.closeRound(EndReasonWon, user)
+		won.Scores = gi.scoreRound()
+		won.Bets = gi.settleBets(false)
+		happened = append(happened, won)
+		gi.awardBadges(user, guess == ad.Price)
 
-func ScoreFor(user string, guildId int) {
-	_, err := db.Conn.Exec(`
-		INSERT INTO scores (username, guild_id)
-		VALUES (?, ?)`, user, guildId)
+		return true, happened, nil
+	}
 
-	if err != nil {
-		log.Printf("Updating score for user %s in guild %d: %v\n", user, guildId, err)
+	for _, hint := range gi.unlockHints() {
+		happened = append(happened, HintUnlocked{GuildId: gi.guildId, Thread: gi.round.threadId, Hint: hint})
 	}
+
+	return false, happened, nil
 }
 
 func NewRound(guildId int) error {
-	var ad olx.OLXAd
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
 
-	tx, err := db.Conn.BeginTx(context.Background(), nil)
+	return gi.newRound()
+}
+
+func (gi *GameInstance) newRound() error {
+	ad, err := gi.nextRoundAd()
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback()
+	startedAt := time.Now()
 
-	_, err = tx.Exec(`
-		DELETE FROM rounds
-		WHERE guild_id = ?;
-	`, guildId)
+	roundId, number, err := gi.store.StartRound(gi.guildId, ad.Id, startedAt)
 	if err != nil {
-		log.Printf("could not delete current round for guild %d\n", guildId)
+		log.Println("could not create round for guild ", gi.guildId)
 		return err
 	}
 
-	_, err = tx.Exec(`
-		DELETE FROM guesses
-		WHERE guild_id = ?`, guildId)
+	// rounds that didn't end by themselves were skipped, nobody loses coins on those
+	gi.settleBets(true)
+
+	gi.stopTimer()
+	gi.stopHintTimers()
+	if gi.round.threadId != 0 {
+		instances.dropThread(gi.round.threadId)
+	}
+	gi.round = Round{
+		id:         roundId,
+		number:     number,
+		ad:         &ad,
+		open:       false,
+		announcing: true,
+		startedAt:  startedAt,
+	}
+	gi.prefetch()
+
+	return nil
+}
+
+// StartRound replaces the current round of a guild with a new one.
+// Subscribers get to show the new ad before it opens for guesses
+func StartRound(guildId int) error {
+	err := NewRound(guildId)
 	if err != nil {
-		log.Printf("could not delete guesses for guild id %d\n", guildId)
 		return err
 	}
 
-	row := tx.QueryRow(`
-		SELECT
-			ads.id,
-			ads.title,
-			ads.image,
-			ads.price,
-			ads.location
-		FROM
-			olx_ads ads
-		WHERE ads.category NOT IN (
-			SELECT category
-			FROM disabled_categories
-			WHERE guild_id = ?
-		)
-		ORDER BY
-			random()
-		LIMIT 1;
-	`, guildId)
-
-	err = row.Scan(&ad.Id, &ad.Title, &ad.Image, &ad.Price, &ad.Location)
+	return announceRound(guildId)
+}
+
+// announceRound publishes the round NewRound just created and opens it
+func announceRound(guildId int) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+
+	started := RoundStarted{
+		GuildId: guildId,
+		Number:  gi.round.number,
+		Ad:      *gi.round.ad,
+	}
+	gi.mu.Unlock()
+
+	bus.Publish(started)
+	OpenRound(guildId)
+
+	return nil
+}
+
+// NewInstance registers a guild the bot just joined. Guilds that
+// already have an instance are left as they are
+func NewInstance(guildId int) error {
+	if _, ok := instances.get(guildId); ok {
+		return nil
+	}
+
+	store := instances.defaultStore()
+	if store == nil {
+		return ErrNoStore
+	}
+
+	err := store.AddGuild(guildId)
 	if err != nil {
 		return err
 	}
 
-	_, err = tx.Exec(`
-		INSERT INTO rounds (guild_id, ad_id)
-		VALUES (?, ?)
-	`, guildId, ad.Id)
+	instances.add(guildId, NewGameInstance(store, defaultGuildSettings(guildId)))
+	return nil
+}
+
+func OpenRound(guildId int) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return
+	}
+	defer gi.mu.Unlock()
+
+	gi.round.open = true
+	gi.round.announcing = false
+	gi.saveRound()
+	gi.scheduleExpiration()
+	gi.scheduleHints()
+}
+
+// closeRound ends the current round. It stays around in memory
+// until the next one starts so it can be scored and announced
+func (gi *GameInstance) closeRound(reason EndReason, winner string) {
+	gi.round.open = false
+	gi.round.endedAt = time.Now()
+	gi.round.endReason = reason
+	gi.round.winner = winner
+
+	gi.saveRound()
+	gi.stopTimer()
+	gi.stopHintTimers()
+}
+
+func (gi *GameInstance) roundState() RoundState {
+	round := gi.round
+
+	state := RoundState{
+		Id:           round.id,
+		GuildId:      gi.guildId,
+		Number:       round.number,
+		Open:         round.open,
+		GuessCount:   round.guessCount,
+		Guesses:      slices.Clone(round.guesses),
+		Hints:        slices.Clone(round.hints),
+		SamePrice:    slices.Clone(round.samePrice),
+		ClosestGuess: round.ClosestGuess,
+		StartedAt:    round.startedAt,
+		EndedAt:      round.endedAt,
+		EndReason:    round.endReason,
+		Winner:       round.winner,
+		Bets:         slices.Clone(round.bets),
+		ThreadId:     round.threadId,
+	}
+
+	if round.ad != nil {
+		state.Ad = *round.ad
+	}
+
+	return state
+}
+
+func roundFromState(state RoundState) Round {
+	ad := state.Ad
+
+	return Round{
+		id:           state.Id,
+		number:       state.Number,
+		guessCount:   state.GuessCount,
+		guesses:      state.Guesses,
+		ad:           &ad,
+		open:         state.Open,
+		hints:        state.Hints,
+		samePrice:    state.SamePrice,
+		startedAt:    state.StartedAt,
+		endedAt:      state.EndedAt,
+		endReason:    state.EndReason,
+		winner:       state.Winner,
+		bets:         state.Bets,
+		threadId:     state.ThreadId,
+		ClosestGuess: state.ClosestGuess,
+	}
+}
+
+// saveRound persists the state of the current round so it can be
+// restored as is by LoadGuilds
+func (gi *GameInstance) saveRound() {
+	err := gi.store.SaveRound(gi.roundState())
 	if err != nil {
-		log.Println("could not create round for guild ", guildId)
-		return err
+		log.Printf("saving round state for guild %d: %v\n", gi.guildId, err)
+	}
+}
+
+func RoundNumber(guildId int) int {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return gi.round.number
+}
+
+// scheduleExpiration arms the round timer taking into account how long
+// the round has already been running, so rounds loaded after a restart
+// expire at the same time they would have otherwise
+func (gi *GameInstance) scheduleExpiration() {
+	gi.stopTimer()
+
+	timeout := gi.timeout()
+	if timeout <= 0 || gi.round.ad == nil {
+		return
+	}
+
+	ad := gi.round.ad
+	remaining := timeout - time.Since(gi.round.startedAt)
+	gi.timer = time.AfterFunc(remaining, func() {
+		gi.expireRound(ad)
+	})
+}
+
+func (gi *GameInstance) stopTimer() {
+	if gi.timer != nil {
+		gi.timer.Stop()
+		gi.timer = nil
+	}
+}
+
+// expireRound closes a round that ran out of time, scoring it and
+// starting the next one if the guild still has a channel to play in
+func (gi *GameInstance) expireRound(ad *olx.OLXAd) {
+	gi.mu.Lock()
+	// the round might have been won or skipped while the timer was firing
+	if !gi.round.open || gi.round.ad != ad {
+		gi.mu.Unlock()
+		return
+	}
+
+	expired := RoundExpired{
+		GuildId: gi.guildId,
+		Thread:  gi.round.threadId,
+		Ad:      *ad,
+	}
+
+	if gi.mode == ModePriceIsRight {
+		expired.Standings = standings(gi.round.guesses, ad.Price)
+		if len(expired.Standings) > 0 && !expired.Standings[0].Over {
+			expired.Winner = expired.Standings[0].Username
+		}
+	} else if closest, err := gi.closestGuess(); err == nil {
+		expired.Closest = &closest
+	}
+
+	expired.Team = gi.teamName(expired.Winner)
+	gi.closeRound(EndReasonExpired, expired.Winner)
+	expired.Scores = gi.scoreRound()
+	expired.Bets = gi.settleBets(false)
+	gi.awardBadges(expired.Winner, false)
+	channelSet := gi.discordChannelId != 0
+	gi.mu.Unlock()
+
+	bus.Publish(expired)
+
+	if channelSet {
+		err := StartRound(gi.guildId)
+		if err != nil {
+			log.Printf("starting round after expiration in guild %d: %v\n", gi.guildId, err)
+		}
+	}
+}
+
+func SetRoundTimeout(guildId int, timeout time.Duration) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
 	}
+	defer gi.mu.Unlock()
 
-	err = tx.Commit()
+	gi.roundTimeout = timeout
+	err := gi.saveSettings()
 	if err != nil {
 		return err
 	}
 
-	instances[guildId].round = Round{
-		ad:   &ad,
-		open: false,
+	if gi.round.open {
+		gi.scheduleExpiration()
 	}
 
 	return nil
 }
 
-func NewInstance(guildId int) {
-	if _, ok := instances[guildId]; !ok {
-		instances[guildId] = &GameInstance{}
+func RoundTimeout(guildId int) time.Duration {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
 	}
-}
+	defer gi.mu.Unlock()
 
-func OpenRound(guildId int) {
-	instances[guildId].round.open = true
+	return gi.roundTimeout
 }
 
-func closeRound(guildId int) {
-	instances[guildId].round.open = false
-}
+func SetChannel(guildId int, channelId int) error {
+	if other, ok := instances.get(channelId); ok && other.parentId != 0 {
+		return ErrChannelTaken
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
 
-func SetChannel(guildId int, channelId int) {
-	instances[guildId].discordChannelId = channelId
+	gi.discordChannelId = channelId
+	return gi.saveSettings()
 }
 
 func InstanceChannel(guildId int) int {
-	return instances[guildId].discordChannelId
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return gi.discordChannelId
 }
 
 func Ad(guildId int) olx.OLXAd {
-	return *instances[guildId].round.ad
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return olx.OLXAd{}
+	}
+	defer gi.mu.Unlock()
+
+	if gi.round.ad == nil {
+		return olx.OLXAd{}
+	}
+
+	return *gi.round.ad
 }
 
 func GuessCount(guildId int) int {
-	return instances[guildId].round.guessCount
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return gi.round.guessCount
 }
 
 func IsChannelSet(guildId int) bool {
-	instance, instanceExists := instances[guildId]
-
-	if !instanceExists {
+	gi, ok := lockInstance(guildId)
+	if !ok {
 		return false
 	}
+	defer gi.mu.Unlock()
 
-	return instance.discordChannelId != 0
+	return gi.discordChannelId != 0
 }
 
 func HasAd(guildId int) bool {
-	instance, instanceExists := instances[guildId]
-
-	if !instanceExists {
+	gi, ok := lockInstance(guildId)
+	if !ok {
 		return false
 	}
+	defer gi.mu.Unlock()
 
-	return instance.round.ad != nil
+	return gi.round.ad != nil
 }
 
-func LoadGuilds() {
-	instances = make(map[int]*GameInstance)
-
-	guildRows, err := db.Conn.Query(`
-		SELECT g.discord_id, g.game_channel_id
-		FROM guilds g;
-	`)
-	if err != nil {
-		log.Fatalf("loading guilds: %v", err)
-	}
-	defer guildRows.Close()
-
-	for guildRows.Next() {
-		var (
-			guildId         int
-			game_channel_id sql.NullInt64
-		)
-		err := guildRows.Scan(&guildId, &game_channel_id)
-		if err != nil {
-			log.Printf("Loading guild %d: %v\n", guildId, err)
-			continue
-		}
-
-		channelId := int(game_channel_id.Int64)
-		instances[guildId] = &GameInstance{
-			discordChannelId: channelId,
-			round:            Round{},
-		}
+// LoadGuilds restores the game of every guild from store, which
+// also becomes the store of guilds the bot joins from now on
+func LoadGuilds(store Store) error {
+	for _, gi := range instances.all() {
+		gi.mu.Lock()
+		gi.stopTimer()
+		gi.stopHintTimers()
+		gi.mu.Unlock()
 	}
+	instances.reset(store)
+	challenge.reset()
+	duels.reset()
 
-	rows, err := db.Conn.Query(`
-		SELECT g.discord_id, g.game_channel_id, ad.id, ad.title, ad.image, ad.price, ad.location
-		FROM rounds r
-		LEFT JOIN olx_ads ad ON r.ad_id = ad.id
-		LEFT JOIN guilds g ON g.discord_id = r.guild_id
-	`)
+	guilds, err := store.Guilds()
 	if err != nil {
-		log.Fatalf("could not load guilds: %v", err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var (
-			guildId         int
-			game_channel_id sql.NullInt64
-			ad_id           int
-			ad_title        string
-			ad_image        string
-			ad_price        int
-			ad_location     string
-		)
-		err := rows.Scan(&guildId, &game_channel_id, &ad_id, &ad_title, &ad_image, &ad_price, &ad_location)
-		if err != nil {
-			log.Printf("Loading guild %d: %v\n", guildId, err)
-			continue
-		}
+		return err
+	}
 
-		instances[guildId].round.ad = &olx.OLXAd{
-			Id:       ad_id,
-			Title:    ad_title,
-			Image:    ad_image,
-			Price:    ad_price,
-			Location: ad_location,
-		}
+	for _, settings := range guilds {
+		instances.set(settings.GuildId, NewGameInstance(store, settings))
 	}
 
-	guessCount, err := db.Conn.Query(`
-		SELECT COUNT(*) as count, guild_id
-		FROM guesses
-		GROUP BY guild_id`)
+	rounds, err := store.CurrentRounds()
 	if err != nil {
-		log.Printf("could not load guilds: %v\n", err)
+		return err
 	}
-	defer guessCount.Close()
-
-	for guessCount.Next() {
-		var (
-			guildId int
-			count   int
-		)
 
-		err := guessCount.Scan(&count, &guildId)
-		if err != nil {
-			log.Printf("counting guesses for guild: %v\n", err)
+	for _, state := range rounds {
+		gi, ok := instances.get(state.GuildId)
+		if !ok {
+			log.Printf("loading round %d of unknown guild %d\n", state.Id, state.GuildId)
 			continue
 		}
 
-		instances[guildId].round.guessCount = count
-	}
-
-	for k := range instances {
-		if instances[k].round.ad != nil {
-			instances[k].round.open = true
+		gi.mu.Lock()
+		gi.round = roundFromState(state)
+		if gi.round.threadId != 0 {
+			instances.setThread(gi.round.threadId, gi.guildId)
 		}
+		if gi.round.open {
+			gi.scheduleExpiration()
+			gi.scheduleHints()
+			gi.prefetch()
+		}
+		gi.mu.Unlock()
 	}
+
+	return nil
 }
 
-func SamePrice(guildId int) (string, error) {
-	ad := Ad(guildId)
-	round := instances[guildId].round
-	excludeIds := strings.Trim(fmt.Sprint(append(round.samePrice, ad.Id)), "[]")
+func DisabledCategories(guildId int) ([]string, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
 
-	row := db.Conn.QueryRow(`
-		SELECT title, id FROM olx_ads
-		WHERE price = ?
-		AND id NOT IN (?)
-	`, ad.Price, excludeIds)
+	return gi.store.DisabledCategories(guildId)
+}
 
-	var otherItem string
-	var otherItemId int
-	err := row.Scan(&otherItem, &otherItemId)
+func SetCategoryEnabled(guildId int, category string, enabled bool) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
 
+	err := gi.store.SetCategoryEnabled(guildId, category, enabled)
 	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			log.Printf("fetching item of same price: %v\n", err)
-		}
-
-		return "", err
+		return err
 	}
 
-	instances[guildId].round.samePrice = append(instances[guildId].round.samePrice, otherItemId)
-
-	return otherItem, nil
+	gi.dropPrefetched()
+	return nil
 }
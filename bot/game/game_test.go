@@ -2,13 +2,16 @@ package game
 
 import (
 	"os"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/gabrieleiro/olx-bets/bot/db"
 	"github.com/gabrieleiro/olx-bets/bot/olx"
 )
 
-func TestLoadGuilds(t *testing.T) {
+func loadFixtureGuilds(t *testing.T) {
 	t.Setenv("ENV", "test")
 	populateGuilds, err := os.ReadFile("../fixtures/load_guilds.sql")
 	if err != nil {
@@ -21,28 +24,35 @@ func TestLoadGuilds(t *testing.T) {
 		t.Fatalf("running sql for populating guilds:\n%v\n", err)
 	}
 
-	LoadGuilds()
+	err = LoadGuilds(NewSQLStore(db.Conn))
+	if err != nil {
+		t.Fatalf("loading guilds:\n%v\n", err)
+	}
+}
+
+func TestLoadGuilds(t *testing.T) {
+	loadFixtureGuilds(t)
 
 	type TestMatch struct {
 		Name     string
-		Expected GameInstance
+		Expected *GameInstance
 	}
 
 	tests := make(map[int]TestMatch)
 
 	tests[827261239926980668] = TestMatch{
 		Name:     "no game channel and no round",
-		Expected: GameInstance{},
+		Expected: &GameInstance{},
 	}
 
 	tests[927261239926980667] = TestMatch{
 		Name:     "game channel set but not round",
-		Expected: GameInstance{discordChannelId: 1290463177401962537},
+		Expected: &GameInstance{discordChannelId: 1290463177401962537},
 	}
 
 	tests[127261239926980822] = TestMatch{
 		Name: "game channel set and round",
-		Expected: GameInstance{
+		Expected: &GameInstance{
 			discordChannelId: 1230463177401962456,
 			round: Round{
 				ad: &olx.OLXAd{
@@ -58,7 +68,7 @@ func TestLoadGuilds(t *testing.T) {
 
 	tests[666261239926980822] = TestMatch{
 		Name: "no game channel set and round",
-		Expected: GameInstance{
+		Expected: &GameInstance{
 			discordChannelId: 0,
 			round: Round{
 				ad: &olx.OLXAd{
@@ -72,9 +82,56 @@ func TestLoadGuilds(t *testing.T) {
 		},
 	}
 
+	tests[444261239926980123] = TestMatch{
+		Name: "timed round",
+		Expected: &GameInstance{
+			discordChannelId: 1440463177401962111,
+			roundTimeout:     30 * time.Minute,
+			round: Round{
+				open: true,
+				ad: &olx.OLXAd{
+					Id:       4,
+					Title:    "Bicicleta aro 29",
+					Image:    "https://img.olx.com.br/thumbs500x360/12/121519857063037.jpg",
+					Price:    1200,
+					Location: "Curitiba - PR",
+				},
+			},
+		},
+	}
+
+	tests[555261239926980456] = TestMatch{
+		Name: "closed round with hints",
+		Expected: &GameInstance{
+			discordChannelId: 1550463177401962222,
+			hintRules: []HintRule{
+				{Kind: HintClosest, Guesses: 5, Every: 5, Chance: 100},
+				{Kind: HintZeros, Elapsed: 5 * time.Minute, Chance: 50},
+			},
+			round: Round{
+				number:     7,
+				guessCount: 20,
+				hints:      []string{"zeros", "closest:10"},
+				samePrice:  []int{2, 3},
+				startedAt:  time.Unix(1727740800, 0),
+				ClosestGuess: &ClosestGuessHint{
+					Username: "gabrieleiro",
+					Guess:    1450,
+				},
+				ad: &olx.OLXAd{
+					Id:       5,
+					Title:    "Guitarra Tagima",
+					Image:    "https://img.olx.com.br/thumbs500x360/15/151519857063037.jpg",
+					Price:    1500,
+					Location: "Recife - PE",
+				},
+			},
+		},
+	}
+
 	for v, k := range tests {
 		t.Run(k.Name, func(t *testing.T) {
-			g, ok := instances[v]
+			g, ok := instances.get(v)
 
 			if !ok {
 				t.Fatalf("didn't load guild %d\n", v)
@@ -85,11 +142,54 @@ func TestLoadGuilds(t *testing.T) {
 					v, k.Expected.discordChannelId, g.discordChannelId)
 			}
 
+			if g.roundTimeout != k.Expected.roundTimeout {
+				t.Fatalf("round timeout mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.roundTimeout, g.roundTimeout)
+			}
+
+			if !slices.Equal(g.hintRules, k.Expected.hintRules) {
+				t.Fatalf("hint rules mismatch for guild %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.hintRules, g.hintRules)
+			}
+
 			if g.round.guessCount != k.Expected.round.guessCount {
 				t.Fatalf("mismatch guess count for instance %d\n  Want: %d\n  Got: %d\n",
 					v, g.round.guessCount, k.Expected.round.guessCount)
 			}
 
+			if g.round.open != k.Expected.round.open {
+				t.Fatalf("round open mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.round.open, g.round.open)
+			}
+
+			if g.round.ad != nil {
+				expectedNumber := max(k.Expected.round.number, 1)
+				if g.round.number != expectedNumber {
+					t.Fatalf("round number mismatch for instance %d\n  Want: %d\n  Got: %d\n",
+						v, expectedNumber, g.round.number)
+				}
+			}
+
+			if !k.Expected.round.startedAt.IsZero() && !g.round.startedAt.Equal(k.Expected.round.startedAt) {
+				t.Fatalf("round start mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.round.startedAt, g.round.startedAt)
+			}
+
+			if !slices.Equal(g.round.hints, k.Expected.round.hints) {
+				t.Fatalf("hints mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.round.hints, g.round.hints)
+			}
+
+			if !slices.Equal(g.round.samePrice, k.Expected.round.samePrice) {
+				t.Fatalf("same price hints mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.round.samePrice, g.round.samePrice)
+			}
+
+			if !reflect.DeepEqual(g.round.ClosestGuess, k.Expected.round.ClosestGuess) {
+				t.Fatalf("closest guess mismatch for instance %d\n  Want: %v\n  Got: %v\n",
+					v, k.Expected.round.ClosestGuess, g.round.ClosestGuess)
+			}
+
 			actualAd := g.round.ad
 			expectedAd := k.Expected.round.ad
 
@@ -132,3 +232,119 @@ func TestLoadGuilds(t *testing.T) {
 		})
 	}
 }
+
+func TestRoundExpires(t *testing.T) {
+	loadFixtureGuilds(t)
+
+	guildId := 444261239926980123
+	expired := make(chan RoundExpired, 1)
+	started := make(chan RoundStarted, 1)
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Guild() != guildId {
+			return
+		}
+
+		switch e := e.(type) {
+		case RoundExpired:
+			expired <- e
+		case RoundStarted:
+			started <- e
+		}
+	})
+	defer unsubscribe()
+
+	gi, _ := instances.get(guildId)
+
+	gi.mu.Lock()
+	timer := gi.timer
+	gi.mu.Unlock()
+
+	if timer == nil {
+		t.Fatalf("timed round loaded without a timer")
+	}
+
+	SetRoundTimeout(guildId, time.Millisecond)
+
+	select {
+	case e := <-expired:
+		if e.Ad.Id != 4 {
+			t.Fatalf("expired round with wrong ad\n  Want: %d\n  Got: %d\n", 4, e.Ad.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("round did not expire")
+	}
+
+	select {
+	case e := <-started:
+		if e.Ad.Id == 0 {
+			t.Fatalf("next round started without an ad")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("next round did not start after expiring")
+	}
+}
+
+func TestNoRepeatedAds(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+		{Id: 2, Title: "iPhone XR 64Gb - Preto", Price: 850},
+		{Id: 3, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	seen := make(map[int]bool)
+	for range 3 {
+		err := NewRound(guildId)
+		if err != nil {
+			t.Fatalf("starting round: %v\n", err)
+		}
+
+		id := Ad(guildId).Id
+		if seen[id] {
+			t.Fatalf("ad %d repeated before every ad was seen\n", id)
+		}
+		seen[id] = true
+	}
+
+	// every ad was seen, so they're all fair game again
+	err := NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round after seeing every ad: %v\n", err)
+	}
+}
+
+func TestSQLStoreSeenAds(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+
+	guildId := 827261239926980668
+	prices := PriceRange{Min: 1000}
+
+	// ads 4 and 5 are the only ones above R$ 1000
+	for range 2 {
+		ad, err := store.RandomAd(guildId, prices)
+		if err != nil {
+			t.Fatalf("picking ad: %v\n", err)
+		}
+
+		_, _, err = store.StartRound(guildId, ad.Id, time.Now())
+		if err != nil {
+			t.Fatalf("starting round: %v\n", err)
+		}
+	}
+
+	_, err := store.RandomAd(guildId, prices)
+	if err != ErrNoAds {
+		t.Fatalf("picked an ad the guild already saw\n  Want: %v\n  Got: %v\n", ErrNoAds, err)
+	}
+
+	err = store.ResetSeenAds(guildId)
+	if err != nil {
+		t.Fatalf("resetting seen ads: %v\n", err)
+	}
+
+	_, err = store.RandomAd(guildId, prices)
+	if err != nil {
+		t.Fatalf("picking ad after reset: %v\n", err)
+	}
+}
@@ -0,0 +1,21 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// eventually waits for done to hold, for what the game saves in the
+// background. The test fails if it doesn't hold within a second
+func eventually(t *testing.T, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition didn't hold in time\n")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,526 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// Hint is a clue about the price of the current round. Kind identifies
+// what the hint is about, while Text is ready to be shown to players
+type Hint struct {
+	Kind string
+	Text string
+}
+
+const (
+	HintZeros      = "zeros"
+	HintSamePrice  = "same_price"
+	HintClosest    = "closest"
+	HintDigits     = "digits"
+	HintFirstDigit = "first_digit"
+	HintBracket    = "bracket"
+	HintCategory   = "category"
+	HintMasked     = "masked"
+)
+
+// HintStrategy comes up with one kind of hint about the current round
+type HintStrategy interface {
+	// Hint is called with the instance locked. Strategies that can't
+	// give a hint at the moment return an error instead
+	Hint(gi *GameInstance) (Hint, error)
+}
+
+// HintFunc lets plain functions be used as hint strategies
+type HintFunc func(gi *GameInstance) (Hint, error)
+
+func (f HintFunc) Hint(gi *GameInstance) (Hint, error) {
+	return f(gi)
+}
+
+var hintStrategies = map[string]HintStrategy{
+	HintZeros:      HintFunc(zeroesHint),
+	HintSamePrice:  HintFunc(samePriceHint),
+	HintClosest:    HintFunc(closestHint),
+	HintDigits:     adHint(digitsHint),
+	HintFirstDigit: adHint(firstDigitHint),
+	HintBracket:    adHint(bracketHint),
+	HintCategory:   adHint(categoryHint),
+	HintMasked:     adHint(maskedPriceHint),
+}
+
+// HintKinds are the kinds of hints a schedule can have
+var HintKinds = []string{
+	HintZeros,
+	HintSamePrice,
+	HintClosest,
+	HintDigits,
+	HintFirstDigit,
+	HintBracket,
+	HintCategory,
+	HintMasked,
+}
+
+// HintRule schedules one kind of hint. Rules based on guesses fire when
+// the round reaches Guesses guesses and then every Every guesses, if
+// Every isn't zero. Rules based on time fire once, after the round has
+// been running for Elapsed. Each time a rule fires there's a Chance
+// percent chance of the hint actually being given
+type HintRule struct {
+	Kind    string
+	Guesses int
+	Every   int
+	Elapsed time.Duration
+	Chance  int
+}
+
+// DefaultHintSchedule is used for every kind of hint a guild didn't
+// schedule. Hints that give away a lot start off, admins can turn them on
+var DefaultHintSchedule = []HintRule{
+	{Kind: HintZeros, Guesses: 15, Chance: 100},
+	{Kind: HintSamePrice, Guesses: 11, Every: 1, Chance: 6},
+	{Kind: HintClosest, Guesses: 10, Every: 10, Chance: 100},
+	{Kind: HintDigits},
+	{Kind: HintFirstDigit},
+	{Kind: HintBracket},
+	{Kind: HintCategory},
+	{Kind: HintMasked},
+}
+
+var ErrUnknownHint = errors.New("unknown kind of hint")
+
+// Enabled tells whether the rule ever fires
+func (r HintRule) Enabled() bool {
+	return r.Chance > 0 && (r.Guesses > 0 || r.Elapsed > 0)
+}
+
+func (r HintRule) firesAt(guessCount int) bool {
+	if r.Guesses <= 0 || r.Elapsed > 0 {
+		return false
+	}
+
+	if guessCount == r.Guesses {
+		return true
+	}
+
+	return r.Every > 0 && guessCount > r.Guesses && (guessCount-r.Guesses)%r.Every == 0
+}
+
+// key identifies each time the rule fires in the hints given in a round
+func (r HintRule) key(guessCount int) string {
+	if r.Elapsed > 0 {
+		return fmt.Sprintf("%s@%s", r.Kind, r.Elapsed)
+	}
+
+	if r.Every > 0 {
+		return fmt.Sprintf("%s:%d", r.Kind, guessCount)
+	}
+
+	return r.Kind
+}
+
+// hintSchedule is the default schedule with the guild's own rules in place
+func (gi *GameInstance) hintSchedule() []HintRule {
+	schedule := slices.Clone(DefaultHintSchedule)
+
+	for _, rule := range gi.hintRules {
+		idx := slices.IndexFunc(schedule, func(r HintRule) bool { return r.Kind == rule.Kind })
+		if idx == -1 {
+			schedule = append(schedule, rule)
+		} else {
+			schedule[idx] = rule
+		}
+	}
+
+	return schedule
+}
+
+// giveHint asks the rule's strategy for a hint, unless the rule already
+// fired with the same key in this round or it isn't its lucky day
+func (gi *GameInstance) giveHint(rule HintRule, key string) (Hint, bool) {
+	if slices.Contains(gi.round.hints, key) {
+		return Hint{}, false
+	}
+
+	if rule.Chance < 100 && rand.N(100) >= rule.Chance {
+		return Hint{}, false
+	}
+
+	strategy, ok := hintStrategies[rule.Kind]
+	if !ok {
+		return Hint{}, false
+	}
+
+	hint, err := strategy.Hint(gi)
+	if err != nil {
+		return Hint{}, false
+	}
+
+	gi.unlockHint(key)
+	return hint, true
+}
+
+// unlockHints gives out the hints the current round earned with its latest
+// guess. Price is right rounds are played blind, so they never get any
+func (gi *GameInstance) unlockHints() []Hint {
+	if gi.mode == ModePriceIsRight || gi.round.ad == nil {
+		return nil
+	}
+
+	var hints []Hint
+	for _, rule := range gi.hintSchedule() {
+		if !rule.Enabled() || !rule.firesAt(gi.round.guessCount) {
+			continue
+		}
+
+		hint, ok := gi.giveHint(rule, rule.key(gi.round.guessCount))
+		if ok {
+			hints = append(hints, hint)
+		}
+	}
+
+	return hints
+}
+
+// scheduleHints arms a timer for each rule based on time. Like the round
+// timer, it takes into account how long the round has been running
+func (gi *GameInstance) scheduleHints() {
+	gi.stopHintTimers()
+
+	if gi.mode == ModePriceIsRight || gi.round.ad == nil {
+		return
+	}
+
+	ad := gi.round.ad
+	for _, rule := range gi.hintSchedule() {
+		if !rule.Enabled() || rule.Elapsed <= 0 || slices.Contains(gi.round.hints, rule.key(0)) {
+			continue
+		}
+
+		remaining := rule.Elapsed - time.Since(gi.round.startedAt)
+		gi.hintTimers = append(gi.hintTimers, time.AfterFunc(remaining, func() {
+			gi.timedHint(rule, ad)
+		}))
+	}
+}
+
+func (gi *GameInstance) stopHintTimers() {
+	for _, t := range gi.hintTimers {
+		t.Stop()
+	}
+
+	gi.hintTimers = nil
+}
+
+func (gi *GameInstance) timedHint(rule HintRule, ad *olx.OLXAd) {
+	gi.mu.Lock()
+	// the round might have ended while the timer was firing
+	if !gi.round.open || gi.round.ad != ad || gi.mode == ModePriceIsRight {
+		gi.mu.Unlock()
+		return
+	}
+
+	hint, ok := gi.giveHint(rule, rule.key(0))
+	thread := gi.round.threadId
+	gi.mu.Unlock()
+
+	if ok {
+		bus.Publish(HintUnlocked{GuildId: gi.guildId, Thread: thread, Hint: hint})
+	}
+}
+
+// HintSchedule is the schedule the guild's hints follow, including defaults
+func HintSchedule(guildId int) []HintRule {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return nil
+	}
+	defer gi.mu.Unlock()
+
+	return gi.hintSchedule()
+}
+
+// SetHintRule replaces the guild's rule for a kind of hint
+func SetHintRule(guildId int, rule HintRule) error {
+	if _, ok := hintStrategies[rule.Kind]; !ok {
+		return ErrUnknownHint
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.hintRules = slices.DeleteFunc(slices.Clone(gi.hintRules), func(r HintRule) bool { return r.Kind == rule.Kind })
+	gi.hintRules = append(gi.hintRules, rule)
+
+	return gi.applyHintRules()
+}
+
+// ResetHintRule puts a kind of hint back on the default schedule
+func ResetHintRule(guildId int, kind string) error {
+	if _, ok := hintStrategies[kind]; !ok {
+		return ErrUnknownHint
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.hintRules = slices.DeleteFunc(slices.Clone(gi.hintRules), func(r HintRule) bool { return r.Kind == kind })
+
+	return gi.applyHintRules()
+}
+
+func (gi *GameInstance) applyHintRules() error {
+	err := gi.saveSettings()
+	if err != nil {
+		return err
+	}
+
+	if gi.round.open {
+		gi.scheduleHints()
+	}
+
+	return nil
+}
+
+func countZeroes(n int) int {
+	var zeroes int
+	for n > 0 {
+		if n%10 == 0 {
+			zeroes++
+		}
+
+		n /= 10
+	}
+
+	return zeroes
+}
+
+// adHint turns a hint that only depends on the ad into a strategy
+func adHint(f func(ad olx.OLXAd) (Hint, error)) HintFunc {
+	return func(gi *GameInstance) (Hint, error) {
+		if gi.round.ad == nil {
+			return Hint{}, ErrNoAd
+		}
+
+		return f(*gi.round.ad)
+	}
+}
+
+// formatPrice writes a price the way it's written in Brazil, as in 1.500
+func formatPrice(price int) string {
+	return groupThousands(strconv.Itoa(price))
+}
+
+// groupThousands puts a dot between every three digits, counting from the right
+func groupThousands(digits string) string {
+	var res strings.Builder
+	for idx, d := range digits {
+		if idx > 0 && (len(digits)-idx)%3 == 0 {
+			res.WriteRune('.')
+		}
+
+		res.WriteRune(d)
+	}
+
+	return res.String()
+}
+
+func zeroesHint(gi *GameInstance) (Hint, error) {
+	var text string
+	switch zeroes := countZeroes(gi.round.ad.Price); zeroes {
+	case 0:
+		text = "Dica: Não tem nenhum zero no preço desse anúncio"
+	case 1:
+		text = "Dica: Tem um zero no preço desse anúncio"
+	default:
+		text = fmt.Sprintf("Dica: Tem %d zeros no preço desse anúncio", zeroes)
+	}
+
+	return Hint{Kind: HintZeros, Text: text}, nil
+}
+
+func digitsHint(ad olx.OLXAd) (Hint, error) {
+	digits := len(strconv.Itoa(ad.Price))
+
+	text := fmt.Sprintf("Dica: O preço desse anúncio tem %d dígitos", digits)
+	if digits == 1 {
+		text = "Dica: O preço desse anúncio tem um dígito só"
+	}
+
+	return Hint{Kind: HintDigits, Text: text}, nil
+}
+
+// prices need at least this many digits for hints about their digits,
+// otherwise the hint gives most of the price away
+const minHintDigits = 3
+
+var ErrShortPrice = errors.New("price is too short to hint at its digits")
+
+func firstDigitHint(ad olx.OLXAd) (Hint, error) {
+	if len(strconv.Itoa(ad.Price)) < minHintDigits {
+		return Hint{}, ErrShortPrice
+	}
+
+	return Hint{
+		Kind: HintFirstDigit,
+		Text: fmt.Sprintf("Dica: O preço desse anúncio começa com %c", strconv.Itoa(ad.Price)[0]),
+	}, nil
+}
+
+// priceBrackets are the bounds of the ranges used by the bracket hint
+var priceBrackets = []int{0, 100, 500, 1000, 5000, 10000, 50000, 100000, 500000, 1000000}
+
+func bracketHint(ad olx.OLXAd) (Hint, error) {
+	var text string
+
+	for idx := 1; idx < len(priceBrackets); idx++ {
+		if ad.Price < priceBrackets[idx] {
+			text = fmt.Sprintf("Dica: O preço desse anúncio está entre R$ %s e R$ %s",
+				formatPrice(priceBrackets[idx-1]), formatPrice(priceBrackets[idx]))
+			break
+		}
+	}
+
+	if text == "" {
+		text = fmt.Sprintf("Dica: O preço desse anúncio passa de R$ %s", formatPrice(priceBrackets[len(priceBrackets)-1]))
+	}
+
+	return Hint{Kind: HintBracket, Text: text}, nil
+}
+
+var ErrNoCategory = errors.New("ad has no category")
+
+func categoryHint(ad olx.OLXAd) (Hint, error) {
+	if ad.Category == "" {
+		return Hint{}, ErrNoCategory
+	}
+
+	return Hint{
+		Kind: HintCategory,
+		Text: fmt.Sprintf("Dica: Esse anúncio é da categoria %s", ad.Category),
+	}, nil
+}
+
+// maskedPriceHint shows every other digit of the price, starting with the
+// first one, so 1250 becomes R$ 1.?5?
+func maskedPriceHint(ad olx.OLXAd) (Hint, error) {
+	digits := []byte(strconv.Itoa(ad.Price))
+	if len(digits) < minHintDigits {
+		return Hint{}, ErrShortPrice
+	}
+
+	for idx := 1; idx < len(digits); idx += 2 {
+		digits[idx] = '?'
+	}
+
+	return Hint{
+		Kind: HintMasked,
+		Text: fmt.Sprintf("Dica: O preço desse anúncio é R$ %s", groupThousands(string(digits))),
+	}, nil
+}
+
+func samePriceHint(gi *GameInstance) (Hint, error) {
+	other, err := gi.samePrice()
+	if err != nil {
+		return Hint{}, err
+	}
+
+	return Hint{
+		Kind: HintSamePrice,
+		Text: fmt.Sprintf("**%s** tem o mesmo preço de **%s**", gi.round.ad.Title, other),
+	}, nil
+}
+
+func closestHint(gi *GameInstance) (Hint, error) {
+	closest, err := gi.closestGuess()
+	if err != nil {
+		return Hint{}, err
+	}
+
+	return Hint{
+		Kind: HintClosest,
+		Text: fmt.Sprintf("%s foi quem passou mais perto com R$ %d", closest.Username, closest.Value),
+	}, nil
+}
+
+// unlockHint marks a hint as given in the current round. It returns false
+// if the hint was already given, so it isn't repeated
+func (gi *GameInstance) unlockHint(hint string) bool {
+	if slices.Contains(gi.round.hints, hint) {
+		return false
+	}
+
+	gi.round.hints = append(gi.round.hints, hint)
+	gi.saveRound()
+
+	return true
+}
+
+func UnlockHint(guildId int, hint string) bool {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false
+	}
+	defer gi.mu.Unlock()
+
+	return gi.unlockHint(hint)
+}
+
+// samePrice picks an ad with the same price as the current one
+// that wasn't hinted yet in this round
+func (gi *GameInstance) samePrice() (string, error) {
+	if gi.round.ad == nil {
+		return "", ErrNoAd
+	}
+
+	ad := gi.round.ad
+	other, err := gi.store.AdWithPrice(ad.Price, append(slices.Clone(gi.round.samePrice), ad.Id))
+	if err != nil {
+		return "", err
+	}
+
+	gi.round.samePrice = append(gi.round.samePrice, other.Id)
+	gi.saveRound()
+
+	return other.Title, nil
+}
+
+// closestGuess is the guess the fewest reais away from the price, the
+// earliest one on ties
+func (gi *GameInstance) closestGuess() (Guess, error) {
+	if gi.round.ad == nil {
+		return Guess{}, ErrNoGuesses
+	}
+
+	if len(gi.round.guesses) == 0 {
+		return Guess{}, ErrNoGuesses
+	}
+
+	price := gi.round.ad.Price
+	closest := gi.round.guesses[0]
+	for _, g := range gi.round.guesses[1:] {
+		if distance(g.Value, price) < distance(closest.Value, price) {
+			closest = g
+		}
+	}
+
+	gi.round.ClosestGuess = &ClosestGuessHint{
+		Username: closest.Username,
+		Guess:    closest.Value,
+	}
+	gi.saveRound()
+
+	return closest, nil
+}
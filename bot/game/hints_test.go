@@ -0,0 +1,255 @@
+package game
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestHintRuleFiresAt(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Rule     HintRule
+		Fires    []int
+		DontFire []int
+	}{
+		{
+			Name:     "once",
+			Rule:     HintRule{Guesses: 15},
+			Fires:    []int{15},
+			DontFire: []int{1, 14, 16, 30},
+		},
+		{
+			Name:     "every few guesses",
+			Rule:     HintRule{Guesses: 10, Every: 10},
+			Fires:    []int{10, 20, 30},
+			DontFire: []int{5, 15, 25},
+		},
+		{
+			Name:     "every guess",
+			Rule:     HintRule{Guesses: 11, Every: 1},
+			Fires:    []int{11, 12, 50},
+			DontFire: []int{1, 10},
+		},
+		{
+			Name:     "based on time",
+			Rule:     HintRule{Guesses: 10, Elapsed: time.Minute},
+			DontFire: []int{10},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, count := range test.Fires {
+				if !test.Rule.firesAt(count) {
+					t.Fatalf("rule %+v didn't fire at %d guesses\n", test.Rule, count)
+				}
+			}
+
+			for _, count := range test.DontFire {
+				if test.Rule.firesAt(count) {
+					t.Fatalf("rule %+v fired at %d guesses\n", test.Rule, count)
+				}
+			}
+		})
+	}
+}
+
+func TestHintSchedule(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+	}, guildId)
+
+	rule := HintRule{Kind: HintZeros, Guesses: 2, Chance: 100}
+	err := SetHintRule(guildId, rule)
+	if err != nil {
+		t.Fatalf("setting hint rule: %v\n", err)
+	}
+
+	err = SetHintRule(guildId, HintRule{Kind: "palpite"})
+	if err != ErrUnknownHint {
+		t.Fatalf("unknown hint was accepted\n  Want: %v\n  Got: %v\n", ErrUnknownHint, err)
+	}
+
+	// the rule survives a restart
+	err = LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("reloading guilds: %v\n", err)
+	}
+
+	schedule := HintSchedule(guildId)
+	if idx := slices.IndexFunc(schedule, func(r HintRule) bool { return r.Kind == HintZeros }); idx == -1 || schedule[idx] != rule {
+		t.Fatalf("schedule doesn't have the guild's rule\n  Want: %v\n  Got: %v\n", rule, schedule)
+	}
+
+	var hints []Hint
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(HintUnlocked); ok && e.GuildId == guildId {
+			hints = append(hints, e.Hint)
+		}
+	})
+	defer unsubscribe()
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	for _, guess := range []int{100, 200, 300} {
+		CheckGuess(0, "fulano", guess, guildId)
+	}
+
+	expected := []Hint{{Kind: HintZeros, Text: "Dica: Tem um zero no preço desse anúncio"}}
+	if !slices.Equal(hints, expected) {
+		t.Fatalf("hints mismatch\n  Want: %v\n  Got: %v\n", expected, hints)
+	}
+
+	err = ResetHintRule(guildId, HintZeros)
+	if err != nil {
+		t.Fatalf("resetting hint rule: %v\n", err)
+	}
+
+	if !slices.Equal(HintSchedule(guildId), DefaultHintSchedule) {
+		t.Fatalf("schedule wasn't reset\n  Want: %v\n  Got: %v\n", DefaultHintSchedule, HintSchedule(guildId))
+	}
+}
+
+func TestTimedHint(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+	}, guildId)
+
+	hints := make(chan Hint, 1)
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(HintUnlocked); ok && e.GuildId == guildId {
+			hints <- e.Hint
+		}
+	})
+	defer unsubscribe()
+
+	err := SetHintRule(guildId, HintRule{Kind: HintZeros, Elapsed: time.Millisecond, Chance: 100})
+	if err != nil {
+		t.Fatalf("setting hint rule: %v\n", err)
+	}
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	select {
+	case hint := <-hints:
+		if hint.Kind != HintZeros {
+			t.Fatalf("wrong hint\n  Want: %v\n  Got: %v\n", HintZeros, hint.Kind)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed hint wasn't given")
+	}
+}
+
+func TestAdHints(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Strategy func(ad olx.OLXAd) (Hint, error)
+		Ad       olx.OLXAd
+		Expected string
+	}{
+		{"digits", digitsHint, olx.OLXAd{Price: 1250}, "Dica: O preço desse anúncio tem 4 dígitos"},
+		{"single digit", digitsHint, olx.OLXAd{Price: 5}, "Dica: O preço desse anúncio tem um dígito só"},
+		{"first digit", firstDigitHint, olx.OLXAd{Price: 950}, "Dica: O preço desse anúncio começa com 9"},
+		{"first digit of a car", firstDigitHint, olx.OLXAd{Price: 52990}, "Dica: O preço desse anúncio começa com 5"},
+		{"bracket", bracketHint, olx.OLXAd{Price: 750}, "Dica: O preço desse anúncio está entre R$ 500 e R$ 1.000"},
+		{"bracket lower bound", bracketHint, olx.OLXAd{Price: 1000}, "Dica: O preço desse anúncio está entre R$ 1.000 e R$ 5.000"},
+		{"cheapest bracket", bracketHint, olx.OLXAd{Price: 30}, "Dica: O preço desse anúncio está entre R$ 0 e R$ 100"},
+		{"past the brackets", bracketHint, olx.OLXAd{Price: 2500000}, "Dica: O preço desse anúncio passa de R$ 1.000.000"},
+		{"category", categoryHint, olx.OLXAd{Price: 1500, Category: "Música e Hobbies"}, "Dica: Esse anúncio é da categoria Música e Hobbies"},
+		{"masked", maskedPriceHint, olx.OLXAd{Price: 1250}, "Dica: O preço desse anúncio é R$ 1.?5?"},
+		{"masked three digits", maskedPriceHint, olx.OLXAd{Price: 950}, "Dica: O preço desse anúncio é R$ 9?0"},
+		{"masked car", maskedPriceHint, olx.OLXAd{Price: 52990}, "Dica: O preço desse anúncio é R$ 5?.9?0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			hint, err := test.Strategy(test.Ad)
+			if err != nil {
+				t.Fatalf("giving hint: %v\n", err)
+			}
+
+			if hint.Text != test.Expected {
+				t.Fatalf("hint mismatch\n  Want: %s\n  Got: %s\n", test.Expected, hint.Text)
+			}
+		})
+	}
+}
+
+func TestCategoryHintWithoutCategory(t *testing.T) {
+	_, err := categoryHint(olx.OLXAd{Price: 100})
+	if err != ErrNoCategory {
+		t.Fatalf("hinted an ad without category\n  Want: %v\n  Got: %v\n", ErrNoCategory, err)
+	}
+}
+
+func TestAdHintWithoutAd(t *testing.T) {
+	_, err := adHint(digitsHint)(&GameInstance{})
+	if err != ErrNoAd {
+		t.Fatalf("hinted a round without an ad\n  Want: %v\n  Got: %v\n", ErrNoAd, err)
+	}
+}
+
+func TestDigitHintsOnShortPrices(t *testing.T) {
+	for _, price := range []int{7, 95} {
+		for _, strategy := range []func(ad olx.OLXAd) (Hint, error){firstDigitHint, maskedPriceHint} {
+			hint, err := strategy(olx.OLXAd{Price: price})
+			if err != ErrShortPrice {
+				t.Fatalf("hinted at the digits of R$ %d\n  Want: %v\n  Got: %v %v\n", price, ErrShortPrice, hint, err)
+			}
+		}
+	}
+}
+
+func TestClosestGuess(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1000},
+	}, guildId)
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	// 1600 is closer in percent, but 500 is closer in reais and came before 1500
+	for _, guess := range []Guess{{Username: "ana", Value: 1600}, {Username: "bia", Value: 500}, {Username: "caio", Value: 1500}} {
+		_, err = CheckGuess(0, guess.Username, guess.Value, guildId)
+		if err != nil {
+			t.Fatalf("guessing: %v\n", err)
+		}
+	}
+
+	gi, _ := lockInstance(guildId)
+	closest, err := gi.closestGuess()
+	gi.mu.Unlock()
+	if err != nil {
+		t.Fatalf("finding closest guess: %v\n", err)
+	}
+
+	if closest.Username != "bia" {
+		t.Fatalf("closest guess mismatch\n  Want: bia\n  Got: %s\n", closest.Username)
+	}
+}
+
+func TestHintStrategies(t *testing.T) {
+	for _, kind := range HintKinds {
+		if _, ok := hintStrategies[kind]; !ok {
+			t.Fatalf("no strategy for hint %s\n", kind)
+		}
+
+		if !slices.ContainsFunc(DefaultHintSchedule, func(r HintRule) bool { return r.Kind == kind }) {
+			t.Fatalf("hint %s missing from the default schedule\n", kind)
+		}
+	}
+}
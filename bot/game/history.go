@@ -0,0 +1,49 @@
+package game
+
+import (
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+type EndReason string
+
+const (
+	EndReasonWon     EndReason = "acerto"
+	EndReasonExpired EndReason = "tempo"
+	EndReasonSkipped EndReason = "pulada"
+)
+
+// ArchivedRound is a round that already ended, along with every guess made in it
+type ArchivedRound struct {
+	Id         int
+	Number     int
+	Ad         olx.OLXAd
+	StartedAt  time.Time
+	EndedAt    time.Time
+	EndReason  EndReason
+	Winner     string
+	GuessCount int
+	Guesses    []Guess
+}
+
+// HistoryLen is how many rounds the guild has already finished
+func HistoryLen(guildId int) (int, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return 0, ErrNoInstance
+	}
+
+	return gi.store.FinishedRoundsCount(guildId)
+}
+
+// History fetches the finished rounds of a guild, most recent first.
+// offset 0 is the last round that ended
+func History(guildId int, offset int) (ArchivedRound, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return ArchivedRound{}, ErrNoInstance
+	}
+
+	return gi.store.FinishedRound(guildId, offset)
+}
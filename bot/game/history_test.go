@@ -0,0 +1,47 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	loadFixtureGuilds(t)
+
+	guildId := 555261239926980456
+
+	count, err := HistoryLen(guildId)
+	if err != nil {
+		t.Fatalf("counting finished rounds: %v\n", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("finished rounds mismatch\n  Want: %d\n  Got: %d\n", 1, count)
+	}
+
+	if gi, _ := instances.get(guildId); gi.round.id == 6 {
+		t.Fatalf("finished round loaded as the current one")
+	}
+
+	round, err := History(guildId, 0)
+	if err != nil {
+		t.Fatalf("fetching history: %v\n", err)
+	}
+
+	if round.Number != 6 || round.Ad.Id != 1 || round.Winner != "gabrieleiro" || round.EndReason != EndReasonWon {
+		t.Fatalf("archived round mismatch\n  Got: %+v\n", round)
+	}
+
+	if !round.EndedAt.Equal(time.Unix(1727654700, 0)) {
+		t.Fatalf("archived round end mismatch\n  Want: %v\n  Got: %v\n", time.Unix(1727654700, 0), round.EndedAt)
+	}
+
+	if len(round.Guesses) != 2 {
+		t.Fatalf("archived guesses mismatch\n  Want: %d\n  Got: %d\n", 2, len(round.Guesses))
+	}
+
+	first := round.Guesses[0]
+	if first.Username != "fulano" || first.Value != 900 || !first.CreatedAt.Equal(time.Unix(1727654500, 0)) {
+		t.Fatalf("archived guess mismatch\n  Got: %+v\n", first)
+	}
+}
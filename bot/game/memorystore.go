@@ -0,0 +1,807 @@
+package game
+
+import (
+	"database/sql"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+type memoryScore struct {
+	guildId  int
+	username string
+	points   int
+}
+
+type memoryLedgerEntry struct {
+	guildId  int
+	username string
+	entry    LedgerEntry
+}
+
+type memoryBadge struct {
+	guildId  int
+	username string
+	badge    UnlockedBadge
+}
+
+type memoryTeamScore struct {
+	guildId int
+	teamId  int
+	points  int
+}
+
+// MemoryStore keeps everything in memory. It's safe for concurrent use
+type MemoryStore struct {
+	mu                 sync.Mutex
+	guilds             map[int]GuildSettings
+	rounds             []RoundState
+	scores             []memoryScore
+	ads                []olx.OLXAd
+	disabledCategories map[int][]string
+	seenAds            map[int][]int
+	teams              map[int][]Team
+	teamMembers        map[int]map[string]int
+	teamScores         []memoryTeamScore
+	bets               []Bet
+	ledger             []memoryLedgerEntry
+	// challenge ads by the unix time their day starts
+	challenges        map[int64]int
+	challengeAttempts []ChallengeAttempt
+	duels             []Duel
+	badges            []memoryBadge
+	ratings           map[int]map[string]Rating
+	nextGuessId       int
+	nextTeamId        int
+}
+
+func NewMemoryStore(ads []olx.OLXAd) *MemoryStore {
+	return &MemoryStore{
+		guilds:             make(map[int]GuildSettings),
+		ads:                ads,
+		disabledCategories: make(map[int][]string),
+		seenAds:            make(map[int][]int),
+		teams:              make(map[int][]Team),
+		teamMembers:        make(map[int]map[string]int),
+		challenges:         make(map[int64]int),
+		ratings:            make(map[int]map[string]Rating),
+	}
+}
+
+func (s *MemoryStore) Guilds() ([]GuildSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]GuildSettings, 0, len(s.guilds))
+	for _, g := range s.guilds {
+		res = append(res, g)
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) AddGuild(guildId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.guilds[guildId]; !ok {
+		s.guilds[guildId] = defaultGuildSettings(guildId)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) SaveGuild(settings GuildSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings.HintRules = slices.Clone(settings.HintRules)
+	s.guilds[settings.GuildId] = settings
+	return nil
+}
+
+func (s *MemoryStore) RemoveGuild(guildId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.rounds {
+		r := &s.rounds[i]
+		if r.GuildId == guildId && r.EndedAt.IsZero() {
+			r.EndedAt = time.Now()
+			r.EndReason = EndReasonSkipped
+			r.Open = false
+		}
+	}
+
+	delete(s.guilds, guildId)
+	delete(s.disabledCategories, guildId)
+	delete(s.seenAds, guildId)
+	return nil
+}
+
+// round ids are their position in s.rounds plus one
+func (s *MemoryStore) round(id int) (*RoundState, bool) {
+	if id <= 0 || id > len(s.rounds) {
+		return nil, false
+	}
+
+	return &s.rounds[id-1], true
+}
+
+func (s *MemoryStore) CurrentRounds() ([]RoundState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []RoundState
+	for _, r := range s.rounds {
+		if r.EndedAt.IsZero() {
+			r.Guesses = slices.Clone(r.Guesses)
+			r.Bets = nil
+			for _, b := range s.bets {
+				if b.RoundId == r.Id {
+					r.Bets = append(r.Bets, b)
+				}
+			}
+			res = append(res, r)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) StartRound(guildId int, adId int, startedAt time.Time) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.ads, func(ad olx.OLXAd) bool { return ad.Id == adId })
+	if idx == -1 {
+		return 0, 0, sql.ErrNoRows
+	}
+
+	number := 1
+	for i := range s.rounds {
+		r := &s.rounds[i]
+		if r.GuildId != guildId {
+			continue
+		}
+
+		if r.EndedAt.IsZero() {
+			r.EndedAt = startedAt
+			r.EndReason = EndReasonSkipped
+			r.Open = false
+		}
+
+		number = max(number, r.Number+1)
+	}
+
+	if !slices.Contains(s.seenAds[guildId], adId) {
+		s.seenAds[guildId] = append(s.seenAds[guildId], adId)
+	}
+
+	s.rounds = append(s.rounds, RoundState{
+		Id:        len(s.rounds) + 1,
+		GuildId:   guildId,
+		Number:    number,
+		Ad:        s.ads[idx],
+		StartedAt: startedAt,
+	})
+
+	return len(s.rounds), number, nil
+}
+
+func (s *MemoryStore) SaveRound(round RoundState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.round(round.Id)
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	// the guess count and the guesses are kept up to date by AddGuess
+	round.GuessCount = r.GuessCount
+	round.Guesses = r.Guesses
+	*r = round
+
+	return nil
+}
+
+func (s *MemoryStore) finishedRounds(guildId int) []RoundState {
+	var res []RoundState
+	for _, r := range s.rounds {
+		if r.GuildId == guildId && !r.EndedAt.IsZero() {
+			res = append(res, r)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].EndedAt.Equal(res[j].EndedAt) {
+			return res[i].Id > res[j].Id
+		}
+
+		return res[i].EndedAt.After(res[j].EndedAt)
+	})
+
+	return res
+}
+
+func (s *MemoryStore) FinishedRoundsCount(guildId int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.finishedRounds(guildId)), nil
+}
+
+func (s *MemoryStore) FinishedRound(guildId int, offset int) (ArchivedRound, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := s.finishedRounds(guildId)
+	if offset < 0 || offset >= len(finished) {
+		return ArchivedRound{}, sql.ErrNoRows
+	}
+
+	r := finished[offset]
+	return ArchivedRound{
+		Id:         r.Id,
+		Number:     r.Number,
+		Ad:         r.Ad,
+		StartedAt:  r.StartedAt,
+		EndedAt:    r.EndedAt,
+		EndReason:  r.EndReason,
+		Winner:     r.Winner,
+		GuessCount: r.GuessCount,
+		Guesses:    slices.Clone(r.Guesses),
+	}, nil
+}
+
+func (s *MemoryStore) AddGuess(guess Guess) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.round(guess.RoundId)
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	s.nextGuessId++
+	guess.Id = s.nextGuessId
+
+	r.Guesses = append(r.Guesses, guess)
+	r.GuessCount++
+
+	return nil
+}
+
+func (s *MemoryStore) AddScore(guildId int, username string, points int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scores = append(s.scores, memoryScore{
+		guildId:  guildId,
+		username: username,
+		points:   points,
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) Ranking(guildId int) ([]AggregatedScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []AggregatedScore
+	positions := make(map[string]int)
+
+	for _, sc := range s.scores {
+		if sc.guildId != guildId {
+			continue
+		}
+
+		pos, ok := positions[sc.username]
+		if !ok {
+			positions[sc.username] = len(res)
+			res = append(res, AggregatedScore{Username: sc.username})
+			pos = len(res) - 1
+		}
+
+		res[pos].Score += sc.points
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+
+	return res, nil
+}
+
+func (s *MemoryStore) balance(guildId int, username string) int {
+	var res int
+	for _, l := range s.ledger {
+		if l.guildId == guildId && l.username == username {
+			res += l.entry.Amount
+		}
+	}
+
+	return res
+}
+
+// bet ids are their position in s.bets plus one
+func (s *MemoryStore) PlaceBet(bet Bet) (Bet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.balance(bet.GuildId, bet.Username) < bet.Stake {
+		return bet, ErrNotEnoughCoins
+	}
+
+	bet.Id = len(s.bets) + 1
+	s.bets = append(s.bets, bet)
+	s.ledger = append(s.ledger, memoryLedgerEntry{
+		guildId:  bet.GuildId,
+		username: bet.Username,
+		entry:    LedgerEntry{Amount: -bet.Stake, Reason: LedgerBet, BetId: bet.Id, CreatedAt: time.Now()},
+	})
+
+	return bet, nil
+}
+
+func (s *MemoryStore) SettleBets(bets []Bet, reason LedgerReason) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bet := range bets {
+		if bet.Id < 1 || bet.Id > len(s.bets) {
+			return sql.ErrNoRows
+		}
+
+		s.bets[bet.Id-1] = bet
+		if bet.Payout == 0 {
+			continue
+		}
+
+		s.ledger = append(s.ledger, memoryLedgerEntry{
+			guildId:  bet.GuildId,
+			username: bet.Username,
+			entry:    LedgerEntry{Amount: bet.Payout, Reason: reason, BetId: bet.Id, CreatedAt: time.Now()},
+		})
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Balance(guildId int, username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.balance(guildId, username), nil
+}
+
+func (s *MemoryStore) Ledger(guildId int, username string, limit int) ([]LedgerEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []LedgerEntry
+	for i := len(s.ledger) - 1; i >= 0 && len(res) < limit; i-- {
+		l := s.ledger[i]
+		if l.guildId == guildId && l.username == username {
+			res = append(res, l.entry)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) ClaimDaily(guildId int, username string, amount int, since time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, l := range s.ledger {
+		if l.guildId == guildId && l.username == username && l.entry.Reason == LedgerDaily && !l.entry.CreatedAt.Before(since) {
+			return false, nil
+		}
+	}
+
+	s.ledger = append(s.ledger, memoryLedgerEntry{
+		guildId:  guildId,
+		username: username,
+		entry:    LedgerEntry{Amount: amount, Reason: LedgerDaily, CreatedAt: time.Now()},
+	})
+
+	return true, nil
+}
+
+func (s *MemoryStore) AddTeam(guildId int, name string, roleId int) (Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.teams[guildId], func(t Team) bool { return t.Name == name }) {
+		return Team{}, ErrTeamExists
+	}
+
+	s.nextTeamId++
+	t := Team{Id: s.nextTeamId, Name: name, RoleId: roleId}
+	s.teams[guildId] = append(s.teams[guildId], t)
+
+	return t, nil
+}
+
+func (s *MemoryStore) Teams(guildId int) ([]Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.teams[guildId]), nil
+}
+
+func (s *MemoryStore) SetTeamMember(guildId int, username string, teamId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.teamMembers[guildId] == nil {
+		s.teamMembers[guildId] = make(map[string]int)
+	}
+
+	if teamId == 0 {
+		delete(s.teamMembers[guildId], username)
+	} else {
+		s.teamMembers[guildId][username] = teamId
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) TeamOf(guildId int, username string) (Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teamId, ok := s.teamMembers[guildId][username]
+	if !ok {
+		return Team{}, ErrNoTeam
+	}
+
+	idx := slices.IndexFunc(s.teams[guildId], func(t Team) bool { return t.Id == teamId })
+	if idx == -1 {
+		return Team{}, ErrNoTeam
+	}
+
+	return s.teams[guildId][idx], nil
+}
+
+func (s *MemoryStore) AddTeamScore(guildId int, teamId int, points int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.teamScores = append(s.teamScores, memoryTeamScore{
+		guildId: guildId,
+		teamId:  teamId,
+		points:  points,
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) TeamRanking(guildId int) ([]TeamScore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []TeamScore
+	positions := make(map[int]int)
+
+	for _, sc := range s.teamScores {
+		if sc.guildId != guildId {
+			continue
+		}
+
+		pos, ok := positions[sc.teamId]
+		if !ok {
+			idx := slices.IndexFunc(s.teams[guildId], func(t Team) bool { return t.Id == sc.teamId })
+			if idx == -1 {
+				continue
+			}
+
+			positions[sc.teamId] = len(res)
+			res = append(res, TeamScore{Team: s.teams[guildId][idx].Name})
+			pos = len(res) - 1
+		}
+
+		res[pos].Score += sc.points
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+
+	return res, nil
+}
+
+func (s *MemoryStore) RandomAd(guildId int, prices PriceRange) (olx.OLXAd, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var candidates []olx.OLXAd
+	for _, ad := range s.ads {
+		if prices.Contains(ad.Price) &&
+			!slices.Contains(s.disabledCategories[guildId], ad.Category) &&
+			!slices.Contains(s.seenAds[guildId], ad.Id) {
+			candidates = append(candidates, ad)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return olx.OLXAd{}, ErrNoAds
+	}
+
+	return candidates[rand.N(len(candidates))], nil
+}
+
+func (s *MemoryStore) Challenge(day time.Time) (olx.OLXAd, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	adId, ok := s.challenges[day.Unix()]
+	if !ok {
+		return olx.OLXAd{}, ErrNoChallenge
+	}
+
+	idx := slices.IndexFunc(s.ads, func(ad olx.OLXAd) bool { return ad.Id == adId })
+	if idx == -1 {
+		return olx.OLXAd{}, sql.ErrNoRows
+	}
+
+	return s.ads[idx], nil
+}
+
+func (s *MemoryStore) StartChallenge(day time.Time, adId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[day.Unix()] = adId
+	if !slices.Contains(s.seenAds[challengeGuild], adId) {
+		s.seenAds[challengeGuild] = append(s.seenAds[challengeGuild], adId)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) AddChallengeAttempt(attempt ChallengeAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challengeAttempts = append(s.challengeAttempts, attempt)
+	return nil
+}
+
+func (s *MemoryStore) ChallengeAttempts(day time.Time) ([]ChallengeAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []ChallengeAttempt
+	for _, a := range s.challengeAttempts {
+		if a.Day.Equal(day) {
+			res = append(res, a)
+		}
+	}
+
+	return res, nil
+}
+
+// duel ids are their position in s.duels plus one
+func (s *MemoryStore) StartDuel(duel Duel) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	duel.Id = len(s.duels) + 1
+	s.duels = append(s.duels, duel)
+	if !slices.Contains(s.seenAds[duel.GuildId], duel.Ad.Id) {
+		s.seenAds[duel.GuildId] = append(s.seenAds[duel.GuildId], duel.Ad.Id)
+	}
+
+	return duel.Id, nil
+}
+
+func (s *MemoryStore) FinishDuel(duel Duel) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if duel.Id < 1 || duel.Id > len(s.duels) {
+		return sql.ErrNoRows
+	}
+
+	duel.ChallengerGuesses = slices.Clone(duel.ChallengerGuesses)
+	duel.OpponentGuesses = slices.Clone(duel.OpponentGuesses)
+	s.duels[duel.Id-1] = duel
+
+	return nil
+}
+
+func (s *MemoryStore) Ratings(guildId int) ([]Rating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []Rating
+	for _, r := range s.ratings[guildId] {
+		res = append(res, r)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Rating == res[j].Rating {
+			return res[i].Username < res[j].Username
+		}
+
+		return res[i].Rating > res[j].Rating
+	})
+
+	return res, nil
+}
+
+func (s *MemoryStore) SaveRatings(guildId int, ratings map[string]float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ratings[guildId] == nil {
+		s.ratings[guildId] = make(map[string]Rating)
+	}
+
+	for username, rating := range ratings {
+		r := s.ratings[guildId][username]
+		r.Username = username
+		r.Rating = rating
+		r.Rounds++
+		s.ratings[guildId][username] = r
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) WinsByCategory(guildId int, username string) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wins := make(map[string]int)
+	for _, r := range s.rounds {
+		if r.Winner != username || r.EndedAt.IsZero() {
+			continue
+		}
+
+		if !s.inGuild(r.GuildId, guildId) {
+			continue
+		}
+
+		wins[r.Ad.Category]++
+	}
+
+	return wins, nil
+}
+
+// inGuild tells whether the game is the guild's main game or one of its games in other channels
+func (s *MemoryStore) inGuild(gameId int, guildId int) bool {
+	g, ok := s.guilds[gameId]
+	return ok && (gameId == guildId || g.ParentId == guildId)
+}
+
+func (s *MemoryStore) UserGuesses(guildId int, userId int, username string) ([]GuessRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []GuessRecord
+	for _, r := range s.rounds {
+		if r.EndedAt.IsZero() || !s.inGuild(r.GuildId, guildId) {
+			continue
+		}
+
+		for _, g := range r.Guesses {
+			if g.UserId != userId && (g.UserId != 0 || g.Username != username) {
+				continue
+			}
+
+			res = append(res, GuessRecord{
+				RoundId:  r.Id,
+				Value:    g.Value,
+				Price:    r.Ad.Price,
+				Category: r.Ad.Category,
+				Won:      r.Winner == g.Username,
+			})
+		}
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) UnlockBadge(guildId int, username string, badge Badge, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.badges {
+		if b.guildId == guildId && b.username == username && b.badge.Badge == badge {
+			return false, nil
+		}
+	}
+
+	s.badges = append(s.badges, memoryBadge{guildId, username, UnlockedBadge{Badge: badge, UnlockedAt: at}})
+	return true, nil
+}
+
+func (s *MemoryStore) Badges(guildId int, username string) ([]UnlockedBadge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []UnlockedBadge
+	for _, b := range s.badges {
+		if b.guildId == guildId && b.username == username {
+			res = append(res, b.badge)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *MemoryStore) DuelRecord(guildId int, username string) (DuelRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var record DuelRecord
+	for _, d := range s.duels {
+		if d.GuildId != guildId || d.EndedAt.IsZero() || (d.Challenger != username && d.Opponent != username) {
+			continue
+		}
+
+		switch d.Winner {
+		case "":
+			record.Draws++
+		case username:
+			record.Wins++
+		default:
+			record.Losses++
+		}
+	}
+
+	return record, nil
+}
+
+func (s *MemoryStore) ResetSeenAds(guildId int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.seenAds, guildId)
+	return nil
+}
+
+func (s *MemoryStore) AdWithPrice(price int, excludeIds []int) (olx.OLXAd, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ad := range s.ads {
+		if ad.Price == price && !slices.Contains(excludeIds, ad.Id) {
+			return ad, nil
+		}
+	}
+
+	return olx.OLXAd{}, sql.ErrNoRows
+}
+
+func (s *MemoryStore) DisabledCategories(guildId int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.disabledCategories[guildId]), nil
+}
+
+func (s *MemoryStore) SetCategoryEnabled(guildId int, category string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	disabled := s.disabledCategories[guildId]
+	idx := slices.Index(disabled, category)
+
+	if enabled && idx != -1 {
+		s.disabledCategories[guildId] = slices.Delete(disabled, idx, idx+1)
+	} else if !enabled && idx == -1 {
+		s.disabledCategories[guildId] = append(disabled, category)
+	}
+
+	return nil
+}
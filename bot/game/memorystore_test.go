@@ -0,0 +1,105 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// newTestStore loads an in-memory game with the given ads and guilds
+func newTestStore(t *testing.T, ads []olx.OLXAd, guildIds ...int) *MemoryStore {
+	store := NewMemoryStore(ads)
+
+	for _, id := range guildIds {
+		err := store.AddGuild(id)
+		if err != nil {
+			t.Fatalf("adding guild %d: %v\n", id, err)
+		}
+	}
+
+	err := LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("loading guilds: %v\n", err)
+	}
+
+	return store
+}
+
+// waitForGuesses waits for the guesses that are saved in the background
+func waitForGuesses(t *testing.T, store *MemoryStore, roundId int, count int) {
+	t.Helper()
+
+	eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+
+		r, ok := store.round(roundId)
+		return ok && r.GuessCount >= count
+	})
+}
+
+func TestMemoryStoreRound(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 7, Title: "Bicicleta aro 29", Price: 1200, Category: "Esportes e Lazer"},
+	}, guildId)
+
+	var won []RoundWon
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(RoundWon); ok && e.GuildId == guildId {
+			won = append(won, e)
+		}
+	})
+	defer unsubscribe()
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	for _, guess := range []int{1000, 1100} {
+		isRight, err := CheckGuess(0, "fulano", guess, guildId)
+		if err != nil || isRight {
+			t.Fatalf("guess %d should be wrong, got %v and %v\n", guess, isRight, err)
+		}
+	}
+
+	if !UnlockHint(guildId, "zeros") {
+		t.Fatalf("hint wasn't unlocked\n")
+	}
+
+	waitForGuesses(t, store, 1, 2)
+
+	// a restart restores the round as it was
+	err = LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("reloading guilds: %v\n", err)
+	}
+
+	if GuessCount(guildId) != 2 || Ad(guildId).Id != 7 || UnlockHint(guildId, "zeros") {
+		t.Fatalf("round wasn't restored\n")
+	}
+
+	isRight, err := CheckGuess(0, "gabrieleiro", 1200, guildId)
+	if err != nil || !isRight {
+		t.Fatalf("exact guess should win, got %v and %v\n", isRight, err)
+	}
+
+	if len(won) != 1 {
+		t.Fatalf("expected one win to be published, got %d\n", len(won))
+	}
+
+	scores := won[0].Scores
+	if won[0].Winner != "gabrieleiro" || len(scores) != 2 || scores[0].Username != "gabrieleiro" {
+		t.Fatalf("unexpected scores for the round: %v\n", scores)
+	}
+
+	round, err := History(guildId, 0)
+	if err != nil {
+		t.Fatalf("fetching history: %v\n", err)
+	}
+
+	if round.Winner != "gabrieleiro" || round.EndReason != EndReasonWon {
+		t.Fatalf("archived round mismatch\n  Got: %+v\n", round)
+	}
+}
@@ -0,0 +1,151 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+type Mode string
+
+const (
+	// ModeClassic rounds end as soon as someone guesses the exact price
+	ModeClassic Mode = "classico"
+	// ModePriceIsRight rounds give each user a limited number of guesses and,
+	// when time runs out, the closest guess that doesn't go over the price wins
+	ModePriceIsRight Mode = "preco_certo"
+)
+
+var Modes = []Mode{ModeClassic, ModePriceIsRight}
+
+const DefaultMaxGuesses = 3
+
+// price is right rounds only end when time runs out, so they need
+// a limit even in guilds that didn't configure one
+const defaultPriceIsRightTimeout = 5 * time.Minute
+
+var ErrNoGuessesLeft = errors.New("no guesses left")
+
+type Standing struct {
+	Username string
+	Guess    int
+	Over     bool
+}
+
+func (gi *GameInstance) timeout() time.Duration {
+	if gi.mode == ModePriceIsRight && gi.roundTimeout <= 0 {
+		return defaultPriceIsRightTimeout
+	}
+
+	return gi.roundTimeout
+}
+
+func (gi *GameInstance) guessesLeft(user string) int {
+	left := gi.maxGuesses
+	for _, g := range gi.round.guesses {
+		if g.Username == user {
+			left--
+		}
+	}
+
+	return max(left, 0)
+}
+
+// standings ranks the best guess of each user without going over the price.
+// Users who only guessed above the price come last, ordered by how little
+// they went over. Ties go to whoever guessed first
+func standings(guesses []Guess, price int) []Standing {
+	var res []Standing
+	positions := make(map[string]int)
+
+	better := func(a Standing, b Standing) bool {
+		if a.Over != b.Over {
+			return !a.Over
+		}
+
+		if a.Over {
+			return a.Guess < b.Guess
+		}
+
+		return a.Guess > b.Guess
+	}
+
+	for _, g := range guesses {
+		st := Standing{
+			Username: g.Username,
+			Guess:    g.Value,
+			Over:     g.Value > price,
+		}
+
+		pos, ok := positions[g.Username]
+		if !ok {
+			positions[g.Username] = len(res)
+			res = append(res, st)
+		} else if better(st, res[pos]) {
+			res[pos] = st
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return better(res[i], res[j])
+	})
+
+	return res
+}
+
+// Standings of the current round according to price is right rules
+func Standings(guildId int) []Standing {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return nil
+	}
+	defer gi.mu.Unlock()
+
+	if gi.round.ad == nil {
+		return nil
+	}
+
+	return standings(gi.round.guesses, gi.round.ad.Price)
+}
+
+func GameMode(guildId int) Mode {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ModeClassic
+	}
+	defer gi.mu.Unlock()
+
+	return gi.mode
+}
+
+func GuessesLeft(guildId int, user string) int {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return gi.guessesLeft(user)
+}
+
+func SetMode(guildId int, mode Mode, maxGuesses int) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.mode = mode
+	gi.maxGuesses = maxGuesses
+	err := gi.saveSettings()
+	if err != nil {
+		return err
+	}
+
+	if gi.round.open {
+		gi.scheduleExpiration()
+		gi.scheduleHints()
+	}
+
+	return nil
+}
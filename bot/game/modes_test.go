@@ -0,0 +1,80 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestStandings(t *testing.T) {
+	guesses := []Guess{
+		{Username: "ana", Value: 1200},
+		{Username: "bia", Value: 700},
+		{Username: "caio", Value: 1050},
+		{Username: "ana", Value: 900},
+		{Username: "bia", Value: 1001},
+		{Username: "davi", Value: 1010},
+	}
+
+	expected := []Standing{
+		{Username: "ana", Guess: 900},
+		{Username: "bia", Guess: 700},
+		{Username: "davi", Guess: 1010, Over: true},
+		{Username: "caio", Guess: 1050, Over: true},
+	}
+
+	got := standings(guesses, 1000)
+	if len(got) != len(expected) {
+		t.Fatalf("standings mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("standing #%d mismatch\n  Want: %v\n  Got: %v\n", i+1, expected[i], got[i])
+		}
+	}
+}
+
+func TestPriceIsRightGuessLimit(t *testing.T) {
+	guildId := 1
+	instances = newRegistry(nil)
+	instances.set(guildId, &GameInstance{
+		guildId:    guildId,
+		mode:       ModePriceIsRight,
+		maxGuesses: 2,
+		round: Round{
+			ad:   &olx.OLXAd{Price: 1000},
+			open: true,
+			guesses: []Guess{
+				{Username: "ana", Value: 500},
+				{Username: "ana", Value: 600},
+				{Username: "bia", Value: 100},
+			},
+		},
+	})
+
+	if left := GuessesLeft(guildId, "bia"); left != 1 {
+		t.Fatalf("guesses left mismatch\n  Want: %d\n  Got: %d\n", 1, left)
+	}
+
+	_, err := CheckGuess(0, "ana", 700, guildId)
+	if err != ErrNoGuessesLeft {
+		t.Fatalf("guess past the limit was accepted\n  Want: %v\n  Got: %v\n", ErrNoGuessesLeft, err)
+	}
+}
+
+func TestPriceIsRightScores(t *testing.T) {
+	got := priceIsRightScores([]Standing{
+		{Username: "ana", Guess: 400},
+		{Username: "bia", Guess: 300},
+		{Username: "caio", Guess: 1050, Over: true},
+	}, 1000)
+
+	expected := []RoundScore{
+		{Username: "ana", Guess: 400, Points: 1},
+	}
+
+	if len(got) != len(expected) || got[0] != expected[0] {
+		t.Fatalf("price is right scores mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+}
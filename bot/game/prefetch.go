@@ -0,0 +1,81 @@
+package game
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// prefetch picks the ad of the next round in the background, so the next
+// round doesn't have to wait for the database to sample one. It must be
+// called with the instance locked
+func (gi *GameInstance) prefetch() {
+	if gi.prefetching || gi.nextAd != nil {
+		return
+	}
+
+	gi.prefetching = true
+	version := gi.prefetchVersion
+	prices := gi.priceRange
+
+	go func() {
+		ad, err := gi.store.RandomAd(gi.guildId, prices)
+
+		gi.mu.Lock()
+		defer gi.mu.Unlock()
+
+		gi.prefetching = false
+
+		// the filters changed or an ad was picked while this one was on its way
+		if version != gi.prefetchVersion {
+			gi.prefetch()
+			return
+		}
+
+		if err != nil {
+			// the guild saw every ad, the next round will have to start over
+			if !errors.Is(err, ErrNoAds) {
+				log.Printf("prefetching ad for guild %d: %v\n", gi.guildId, err)
+			}
+			return
+		}
+
+		gi.nextAd = &ad
+	}()
+}
+
+// dropPrefetched discards the prefetched ad, along with any that's on its
+// way, and prefetches another one. It's meant for when the guild's filters
+// change or a duel takes an ad, and must be called with the instance locked
+func (gi *GameInstance) dropPrefetched() {
+	gi.nextAd = nil
+	gi.prefetchVersion++
+	gi.prefetch()
+}
+
+// nextRoundAd takes the prefetched ad if there's one, otherwise it samples
+// one right away. It must be called with the instance locked
+func (gi *GameInstance) nextRoundAd() (olx.OLXAd, error) {
+	if gi.nextAd != nil {
+		ad := *gi.nextAd
+		gi.nextAd = nil
+		return ad, nil
+	}
+
+	// an ad still on its way could be the same one picked here
+	gi.prefetchVersion++
+
+	ad, err := gi.store.RandomAd(gi.guildId, gi.priceRange)
+	if errors.Is(err, ErrNoAds) {
+		// the guild went through every ad it could get, so they're all fair game again
+		err = gi.store.ResetSeenAds(gi.guildId)
+		if err != nil {
+			return olx.OLXAd{}, err
+		}
+
+		ad, err = gi.store.RandomAd(gi.guildId, gi.priceRange)
+	}
+
+	return ad, err
+}
@@ -0,0 +1,68 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// waitForPrefetch waits for the ad being prefetched in the background
+func waitForPrefetch(t *testing.T, guildId int) *olx.OLXAd {
+	t.Helper()
+	gi, _ := instances.get(guildId)
+
+	var next *olx.OLXAd
+	eventually(t, func() bool {
+		gi.mu.Lock()
+		defer gi.mu.Unlock()
+
+		next = gi.nextAd
+		return !gi.prefetching
+	})
+
+	return next
+}
+
+func TestPrefetch(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 250},
+		{Id: 2, Title: "iPhone XR 64Gb - Preto", Price: 850},
+		{Id: 3, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	err := SetPriceRange(guildId, PriceRange{Max: 300})
+	if err != nil {
+		t.Fatalf("setting price range: %v\n", err)
+	}
+
+	err = NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	// the only ad within the range was just seen
+	if next := waitForPrefetch(t, guildId); next != nil {
+		t.Fatalf("prefetched ad %d outside of the price range\n", next.Id)
+	}
+
+	// changing the filters prefetches an ad that follows them
+	err = SetPriceRange(guildId, PriceRange{Min: 1000})
+	if err != nil {
+		t.Fatalf("setting price range: %v\n", err)
+	}
+
+	next := waitForPrefetch(t, guildId)
+	if next == nil || next.Id != 3 {
+		t.Fatalf("prefetched ad mismatch\n  Want: %d\n  Got: %v\n", 3, next)
+	}
+
+	err = NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	if id := Ad(guildId).Id; id != 3 {
+		t.Fatalf("round didn't use the prefetched ad\n  Want: %d\n  Got: %d\n", 3, id)
+	}
+}
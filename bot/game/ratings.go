@@ -0,0 +1,147 @@
+package game
+
+import (
+	"log"
+	"math"
+	"sync"
+)
+
+// DefaultRating is the rating of players before their first round
+const DefaultRating = 1000
+
+// ratingK is how much a single round can move a rating
+const ratingK = 32
+
+// Rating is how well a player guesses compared to the others they played against
+type Rating struct {
+	Username string
+	Rating   float64
+	Rounds   int
+}
+
+// placing is where a player finished in a round. Players who
+// were just as close share the same rank
+type placing struct {
+	username string
+	rank     int
+}
+
+// ratingsMu serializes rating updates, since games in other
+// channels can share the ratings of their guild
+var ratingsMu sync.Mutex
+
+// roundPlacings ranks everyone who guessed in the current round by their best guess
+func (gi *GameInstance) roundPlacings() []placing {
+	round := gi.round
+	if round.ad == nil {
+		return nil
+	}
+
+	var placings []placing
+	if gi.mode == ModePriceIsRight {
+		st := standings(round.guesses, round.ad.Price)
+		for idx, s := range st {
+			rank := idx
+			if idx > 0 && s.Guess == st[idx-1].Guess && s.Over == st[idx-1].Over {
+				rank = placings[idx-1].rank
+			}
+
+			placings = append(placings, placing{s.Username, rank})
+		}
+
+		return placings
+	}
+
+	best := bestGuesses(round.guesses, round.ad.Price)
+	for idx, g := range best {
+		rank := idx
+		if idx > 0 && distance(g.Value, round.ad.Price) == distance(best[idx-1].Value, round.ad.Price) {
+			rank = placings[idx-1].rank
+		}
+
+		placings = append(placings, placing{g.Username, rank})
+	}
+
+	return placings
+}
+
+// expectedScore is the chance a player rated a has of beating one rated b
+func expectedScore(a float64, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// rateRound is the new rating of each player, counting the round as a match
+// between every pair of players in it. Players without a rating start at DefaultRating
+func rateRound(ratings map[string]float64, placings []placing) map[string]float64 {
+	if len(placings) < 2 {
+		return nil
+	}
+
+	current := func(username string) float64 {
+		if r, ok := ratings[username]; ok {
+			return r
+		}
+
+		return DefaultRating
+	}
+
+	res := make(map[string]float64, len(placings))
+	for _, p := range placings {
+		var delta float64
+		for _, other := range placings {
+			if other.username == p.username {
+				continue
+			}
+
+			actual := 0.5
+			if p.rank < other.rank {
+				actual = 1
+			} else if p.rank > other.rank {
+				actual = 0
+			}
+
+			delta += actual - expectedScore(current(p.username), current(other.username))
+		}
+
+		res[p.username] = current(p.username) + ratingK*delta/float64(len(placings)-1)
+	}
+
+	return res
+}
+
+// rate updates the ratings of the players of a round that just ended
+func (gi *GameInstance) rate(rankingId int, placings []placing) {
+	ratingsMu.Lock()
+	defer ratingsMu.Unlock()
+
+	saved, err := gi.store.Ratings(rankingId)
+	if err != nil {
+		log.Printf("fetching ratings of guild %d: %v\n", rankingId, err)
+		return
+	}
+
+	ratings := make(map[string]float64, len(saved))
+	for _, r := range saved {
+		ratings[r.Username] = r.Rating
+	}
+
+	updated := rateRound(ratings, placings)
+	if updated == nil {
+		return
+	}
+
+	err = gi.store.SaveRatings(rankingId, updated)
+	if err != nil {
+		log.Printf("saving ratings of guild %d: %v\n", rankingId, err)
+	}
+}
+
+// Ratings of the game's players, from the highest
+func Ratings(guildId int) ([]Rating, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.Ratings(gi.rankingId())
+}
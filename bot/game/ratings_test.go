@@ -0,0 +1,127 @@
+package game
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestRateRound(t *testing.T) {
+	near := func(a float64, b float64) bool {
+		return math.Abs(a-b) < 0.01
+	}
+
+	got := rateRound(nil, []placing{{"ana", 0}, {"bia", 1}})
+	if !near(got["ana"], DefaultRating+ratingK/2) || !near(got["bia"], DefaultRating-ratingK/2) {
+		t.Fatalf("ratings of evenly matched players mismatch\n  Want: map[ana:%d bia:%d]\n  Got: %v\n", DefaultRating+ratingK/2, DefaultRating-ratingK/2, got)
+	}
+
+	got = rateRound(nil, []placing{{"ana", 0}, {"bia", 0}})
+	if !near(got["ana"], DefaultRating) || !near(got["bia"], DefaultRating) {
+		t.Fatalf("a tie between evenly matched players changed their ratings: %v\n", got)
+	}
+
+	// beating a much weaker player is worth little
+	ratings := map[string]float64{"ana": 1400, "bia": 1000}
+	got = rateRound(ratings, []placing{{"ana", 0}, {"bia", 1}})
+	if gain := got["ana"] - 1400; gain <= 0 || gain >= ratingK/4 {
+		t.Fatalf("rating gained over a weaker player out of range: %.2f\n", gain)
+	}
+
+	// whatever someone gains, someone else loses
+	ratings = map[string]float64{"ana": 1100, "bia": 950}
+	got = rateRound(ratings, []placing{{"caio", 0}, {"ana", 1}, {"bia", 1}})
+	var total float64
+	for username, r := range got {
+		before, ok := ratings[username]
+		if !ok {
+			before = DefaultRating
+		}
+
+		total += r - before
+	}
+
+	if !near(total, 0) {
+		t.Fatalf("ratings changed %.2f in total\n", total)
+	}
+
+	if got := rateRound(nil, []placing{{"ana", 0}}); got != nil {
+		t.Fatalf("a round with a single player changed ratings: %v\n", got)
+	}
+}
+
+func TestRatings(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+		{Id: 2, Title: "Poltrona em tecido", Price: 250},
+	}, guildId)
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	price := Ad(guildId).Price
+	for _, g := range []Guess{{Username: "bia", Value: price + 100}, {Username: "caio", Value: price - 100}, {Username: "ana", Value: price}} {
+		_, err = CheckGuess(0, g.Username, g.Value, guildId)
+		if err != nil {
+			t.Fatalf("checking guess: %v\n", err)
+		}
+	}
+
+	var ratings []Rating
+	eventually(t, func() bool {
+		ratings, err = Ratings(guildId)
+		if err != nil {
+			t.Fatalf("fetching ratings: %v\n", err)
+		}
+
+		return len(ratings) >= 3
+	})
+	if ratings[0].Username != "ana" || ratings[0].Rating <= DefaultRating || ratings[0].Rounds != 1 {
+		t.Fatalf("winner's rating mismatch: %v\n", ratings)
+	}
+
+	// bia and caio were just as many reais off
+	if ratings[1].Rating != ratings[2].Rating || ratings[1].Rating >= DefaultRating {
+		t.Fatalf("ratings of the tied players mismatch: %v\n", ratings)
+	}
+}
+
+func TestSQLStoreRatings(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	err := store.SaveRatings(guildId, map[string]float64{"ana": 1016, "bia": 984})
+	if err != nil {
+		t.Fatalf("saving ratings: %v\n", err)
+	}
+
+	err = store.SaveRatings(guildId, map[string]float64{"bia": 1001.5})
+	if err != nil {
+		t.Fatalf("saving ratings: %v\n", err)
+	}
+
+	got, err := store.Ratings(guildId)
+	if err != nil {
+		t.Fatalf("fetching ratings: %v\n", err)
+	}
+
+	expected := []Rating{
+		{Username: "ana", Rating: 1016, Rounds: 1},
+		{Username: "bia", Rating: 1001.5, Rounds: 2},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("ratings mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for idx := range expected {
+		if got[idx] != expected[idx] {
+			t.Fatalf("rating #%d mismatch\n  Want: %v\n  Got: %v\n", idx+1, expected[idx], got[idx])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package game
+
+import "sync"
+
+// registry maps guild ids to their game instances. It only guards the map
+// itself: reading or changing anything inside an instance requires holding
+// that instance's lock, so guilds never wait on each other
+type registry struct {
+	mu        sync.RWMutex
+	store     Store
+	instances map[int]*GameInstance
+	// threads maps the thread of each round that has one to its game
+	threads map[int]int
+}
+
+func newRegistry(store Store) *registry {
+	return &registry{
+		store:     store,
+		instances: make(map[int]*GameInstance),
+		threads:   make(map[int]int),
+	}
+}
+
+func (r *registry) get(guildId int) (*GameInstance, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	gi, ok := r.instances[guildId]
+	return gi, ok
+}
+
+// add registers gi for the guild unless it already has an instance.
+// Either way, it returns the instance that ended up registered
+func (r *registry) add(guildId int, gi *GameInstance) *GameInstance {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, ok := r.instances[guildId]; ok {
+		return current
+	}
+
+	r.instances[guildId] = gi
+	return gi
+}
+
+func (r *registry) set(guildId int, gi *GameInstance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.instances[guildId] = gi
+}
+
+func (r *registry) remove(guildId int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.instances, guildId)
+}
+
+// all returns a snapshot of the registered instances
+func (r *registry) all() []*GameInstance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]*GameInstance, 0, len(r.instances))
+	for _, gi := range r.instances {
+		res = append(res, gi)
+	}
+
+	return res
+}
+
+// defaultStore is the store given to guilds registered with NewInstance
+func (r *registry) defaultStore() Store {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.store
+}
+
+// reset drops every instance and starts using store for new guilds
+func (r *registry) reset(store Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.store = store
+	r.instances = make(map[int]*GameInstance)
+	r.threads = make(map[int]int)
+}
+
+func (r *registry) setThread(threadId int, guildId int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.threads[threadId] = guildId
+}
+
+func (r *registry) dropThread(threadId int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.threads, threadId)
+}
+
+// threadGame finds the game whose current round is played in the thread
+func (r *registry) threadGame(threadId int) (*GameInstance, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	gi, ok := r.instances[r.threads[threadId]]
+	return gi, ok
+}
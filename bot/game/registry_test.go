@@ -0,0 +1,74 @@
+package game
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// meant to be run with -race
+func TestConcurrentAccess(t *testing.T) {
+	guildId := 1
+	otherGuildId := 2
+
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Poltrona em tecido", Price: 950},
+		{Id: 2, Title: "iPhone XR 64Gb - Preto", Price: 850},
+	}, guildId, otherGuildId)
+
+	err := NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+	OpenRound(guildId)
+
+	const workers = 8
+	const guessesPerWorker = 20
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range guessesPerWorker {
+				// both ads cost more than that, so none of these win the round
+				_, err := CheckGuess(0, "gabrieleiro", 100+i, guildId)
+				if err != nil {
+					t.Errorf("checking guess: %v\n", err)
+				}
+
+				Ad(guildId)
+				HasAd(guildId)
+				GuessCount(guildId)
+				IsClose(100+i, guildId)
+				IsWayOff(100+i, guildId)
+				IsChannelSet(guildId)
+				RoundScores(guildId)
+				Standings(guildId)
+				NewInstance(guildId + w + 10)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for range 3 {
+			err := NewRound(otherGuildId)
+			if err != nil {
+				t.Errorf("starting round: %v\n", err)
+			}
+			OpenRound(otherGuildId)
+			SetChannel(otherGuildId, 1)
+		}
+	}()
+
+	wg.Wait()
+
+	if count := GuessCount(guildId); count != workers*guessesPerWorker {
+		t.Fatalf("guess count mismatch\n  Want: %d\n  Got: %d\n", workers*guessesPerWorker, count)
+	}
+}
@@ -0,0 +1,174 @@
+package game
+
+import (
+	"log"
+	"sort"
+)
+
+// how many of the closest guessers get points at the end of a round
+const scoredGuessers = 3
+
+const exactGuessPoints = 10
+
+// guesses within maxPercentDiff of the price are worth points.
+// Brackets are sorted from the tightest to the loosest
+var pointBrackets = []struct {
+	maxPercentDiff float64
+	points         int
+}{
+	{3, 5},
+	{10, 3},
+	{25, 1},
+}
+
+type RoundScore struct {
+	Username string
+	Guess    int
+	Points   int
+}
+
+// Points is how much a guess is worth for an ad of the given price
+func Points(guess int, price int) int {
+	if guess == price {
+		return exactGuessPoints
+	}
+
+	if isClose(guess, price) {
+		return pointBrackets[0].points
+	}
+
+	diff := PercentDiff(guess, price)
+	for _, b := range pointBrackets {
+		if diff <= b.maxPercentDiff {
+			return b.points
+		}
+	}
+
+	return 0
+}
+
+// bestGuesses keeps only the closest guess of each user, sorted from
+// the closest to the farthest. Ties go to whoever guessed first
+func bestGuesses(guesses []Guess, price int) []Guess {
+	var res []Guess
+	positions := make(map[string]int)
+
+	for _, g := range guesses {
+		pos, ok := positions[g.Username]
+		if !ok {
+			positions[g.Username] = len(res)
+			res = append(res, g)
+		} else if distance(g.Value, price) < distance(res[pos].Value, price) {
+			res[pos] = g
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return distance(res[i].Value, price) < distance(res[j].Value, price)
+	})
+
+	return res
+}
+
+// RoundScores grades the closest guessers of the current round
+func RoundScores(guildId int) []RoundScore {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return nil
+	}
+	defer gi.mu.Unlock()
+
+	return gi.roundScores()
+}
+
+func (gi *GameInstance) roundScores() []RoundScore {
+	round := gi.round
+	if round.ad == nil {
+		return nil
+	}
+
+	if gi.mode == ModePriceIsRight {
+		return priceIsRightScores(standings(round.guesses, round.ad.Price), round.ad.Price)
+	}
+
+	var scores []RoundScore
+	for _, g := range bestGuesses(round.guesses, round.ad.Price) {
+		if len(scores) == scoredGuessers {
+			break
+		}
+
+		points := Points(g.Value, round.ad.Price)
+		if points == 0 {
+			break
+		}
+
+		scores = append(scores, RoundScore{
+			Username: g.Username,
+			Guess:    g.Value,
+			Points:   points,
+		})
+	}
+
+	return scores
+}
+
+// priceIsRightScores only grades guesses that didn't go over the price.
+// The winner always scores, no matter how far below the price they were
+func priceIsRightScores(standings []Standing, price int) []RoundScore {
+	var scores []RoundScore
+	for idx, st := range standings {
+		if len(scores) == scoredGuessers || st.Over {
+			break
+		}
+
+		points := Points(st.Guess, price)
+		if idx == 0 {
+			points = max(points, 1)
+		}
+
+		if points == 0 {
+			break
+		}
+
+		scores = append(scores, RoundScore{
+			Username: st.Username,
+			Guess:    st.Guess,
+			Points:   points,
+		})
+	}
+
+	return scores
+}
+
+func (gi *GameInstance) scoreFor(user string, points int) {
+	err := gi.store.AddScore(gi.rankingId(), user, points)
+	if err != nil {
+		log.Printf("Updating score for user %s in guild %d: %v\n", user, gi.guildId, err)
+	}
+}
+
+// scoreRound saves the points of the current round. It must be called
+// before the next round starts, since that discards the guesses
+func (gi *GameInstance) scoreRound() []RoundScore {
+	scores := gi.roundScores()
+	for _, sc := range scores {
+		go gi.scoreFor(sc.Username, sc.Points)
+	}
+
+	if len(scores) > 0 && gi.teamsOn() {
+		go gi.scoreTeams(scores)
+	}
+
+	go gi.rate(gi.rankingId(), gi.roundPlacings())
+
+	return scores
+}
+
+func Ranking(guildId int) ([]AggregatedScore, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.Ranking(gi.rankingId())
+}
@@ -0,0 +1,107 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestPoints(t *testing.T) {
+	tests := []struct {
+		Guess    int
+		Price    int
+		Expected int
+		Name     string
+	}{
+		{950, 950, 10, "exact guess"},
+		{953, 950, 5, "off by a few reais"},
+		{48_000, 48_990, 5, "within 3%"},
+		{45_000, 48_990, 3, "within 10%"},
+		{40_000, 48_990, 1, "within 25%"},
+		{20_000, 48_990, 0, "way off"},
+		{0, 950, 0, "zero guess"},
+	}
+
+	for _, tt := range tests {
+		got := Points(tt.Guess, tt.Price)
+		if got != tt.Expected {
+			t.Fatalf("%s\n  Want: %d\n  Got: %d\n  Guess: %d, Price: %d\n",
+				tt.Name, tt.Expected, got, tt.Guess, tt.Price)
+		}
+	}
+}
+
+func TestRoundScores(t *testing.T) {
+	guildId := 1
+	instances = newRegistry(nil)
+	instances.set(guildId, &GameInstance{
+		guildId: guildId,
+		round: Round{
+			ad: &olx.OLXAd{Price: 1000},
+			guesses: []Guess{
+				{Username: "ana", Value: 500},
+				{Username: "bia", Value: 980},
+				{Username: "caio", Value: 1300},
+				{Username: "ana", Value: 1000},
+				{Username: "davi", Value: 1100},
+				{Username: "edu", Value: 50},
+			},
+		},
+	})
+
+	expected := []RoundScore{
+		{Username: "ana", Guess: 1000, Points: 10},
+		{Username: "bia", Guess: 980, Points: 5},
+		{Username: "davi", Guess: 1100, Points: 3},
+	}
+
+	got := RoundScores(guildId)
+	if len(got) != len(expected) {
+		t.Fatalf("scored guessers mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("score #%d mismatch\n  Want: %v\n  Got: %v\n", i+1, expected[i], got[i])
+		}
+	}
+}
+
+func TestGuessesOverAndUnderPrice(t *testing.T) {
+	// ana is farther in reais but closer relative to the mean of guess
+	// and price, which used to favor guesses over the price
+	price := 1000
+	guesses := []Guess{
+		{Username: "ana", Value: 1300},
+		{Username: "bia", Value: 750},
+	}
+
+	gi := &GameInstance{
+		store: NewMemoryStore(nil),
+		round: Round{ad: &olx.OLXAd{Price: price}, guesses: guesses},
+	}
+
+	if best := bestGuesses(guesses, price); best[0].Username != "bia" {
+		t.Fatalf("best guess mismatch\n  Want: %q\n  Got: %q\n", "bia", best[0].Username)
+	}
+
+	if placings := gi.roundPlacings(); placings[0].username != "bia" || placings[1].rank != 1 {
+		t.Fatalf("placings mismatch\n  Want: %q first\n  Got: %v\n", "bia", placings)
+	}
+
+	closest, err := gi.closestGuess()
+	if err != nil || closest.Username != "bia" {
+		t.Fatalf("closest guess mismatch\n  Want: %q\n  Got: %q (%v)\n", "bia", closest.Username, err)
+	}
+
+	d := Duel{
+		Challenger:        "ana",
+		Opponent:          "bia",
+		Ad:                olx.OLXAd{Price: price},
+		ChallengerGuesses: []int{1300},
+		OpponentGuesses:   []int{750},
+	}
+	if winner := duelWinner(d); winner != "bia" {
+		t.Fatalf("duel winner mismatch\n  Want: %q\n  Got: %q\n", "bia", winner)
+	}
+}
@@ -0,0 +1,245 @@
+package game
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+const DefaultSkipVotes = 3
+
+// MaxSkipVotes keeps guilds from making rounds impossible to skip
+const MaxSkipVotes = 20
+
+// how long after a skip before the next vote can start
+const SkipCooldown = 2 * time.Minute
+
+// how long a vote to skip stays open
+const SkipVoteDuration = time.Minute
+
+var ErrSkipCooldown = errors.New("rounds were skipped too recently")
+var ErrSkipVoteOpen = errors.New("there's a vote to skip the round already")
+var ErrNoSkipVote = errors.New("there's no vote to skip this round")
+var ErrAlreadyVoted = errors.New("user already voted to skip the round")
+var ErrInvalidSkipVotes = errors.New("invalid number of votes to skip")
+
+// skipVote is a vote to skip a round. It's dropped when the round ends
+type skipVote struct {
+	round     int
+	voters    []string
+	startedAt time.Time
+}
+
+// SkipVote is how a vote to skip a round is going
+type SkipVote struct {
+	Round  int
+	Votes  int
+	Needed int
+	// Passed votes already skipped the round
+	Passed bool
+}
+
+// RoundSkipped is published when a round is skipped, right
+// before the next one starts. Thread is the thread of the skipped round
+type RoundSkipped struct {
+	GuildId int
+	Thread  int
+	Number  int
+	Ad      olx.OLXAd
+}
+
+func (e RoundSkipped) Guild() int { return e.GuildId }
+
+// openSkipVote is the vote to skip the current round, if there's one still going
+func (gi *GameInstance) openSkipVote() *skipVote {
+	v := gi.skipVote
+	if v == nil || v.round != gi.round.number || time.Since(v.startedAt) > SkipVoteDuration {
+		return nil
+	}
+
+	return v
+}
+
+// votesNeeded is how many votes skipping a round takes, even in games that never set it
+func (gi *GameInstance) votesNeeded() int {
+	if gi.skipVotes < 1 {
+		return DefaultSkipVotes
+	}
+
+	return gi.skipVotes
+}
+
+func (gi *GameInstance) voteStatus(v *skipVote) SkipVote {
+	return SkipVote{
+		Round:  v.round,
+		Votes:  len(v.voters),
+		Needed: gi.votesNeeded(),
+	}
+}
+
+// SkipCooldownLeft is how long until the rounds of the guild can be skipped again
+func SkipCooldownLeft(guildId int) time.Duration {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return max(0, SkipCooldown-time.Since(gi.lastSkip))
+}
+
+// StartSkipVote has the user call a vote to skip the current round, their
+// own vote included. Guilds that only need one vote skip right away
+func StartSkipVote(guildId int, username string) (SkipVote, error) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return SkipVote{}, ErrNoInstance
+	}
+
+	if !gi.round.open {
+		gi.mu.Unlock()
+		return SkipVote{}, ErrRoundClosed
+	}
+
+	if time.Since(gi.lastSkip) < SkipCooldown {
+		gi.mu.Unlock()
+		return SkipVote{}, ErrSkipCooldown
+	}
+
+	if gi.openSkipVote() != nil {
+		gi.mu.Unlock()
+		return SkipVote{}, ErrSkipVoteOpen
+	}
+
+	gi.skipVote = &skipVote{
+		round:     gi.round.number,
+		voters:    []string{username},
+		startedAt: time.Now(),
+	}
+	status := gi.voteStatus(gi.skipVote)
+	gi.mu.Unlock()
+
+	return passIfEnough(guildId, status)
+}
+
+// VoteSkip adds the user's vote to skip the round number, skipping
+// it once the vote has the votes the guild needs
+func VoteSkip(guildId int, username string, round int) (SkipVote, error) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return SkipVote{}, ErrNoInstance
+	}
+
+	v := gi.openSkipVote()
+	if v == nil || v.round != round || !gi.round.open {
+		gi.mu.Unlock()
+		return SkipVote{}, ErrNoSkipVote
+	}
+
+	for _, voter := range v.voters {
+		if voter == username {
+			gi.mu.Unlock()
+			return SkipVote{}, ErrAlreadyVoted
+		}
+	}
+
+	v.voters = append(v.voters, username)
+	status := gi.voteStatus(v)
+	gi.mu.Unlock()
+
+	return passIfEnough(guildId, status)
+}
+
+func passIfEnough(guildId int, status SkipVote) (SkipVote, error) {
+	if status.Votes < status.Needed {
+		return status, nil
+	}
+
+	err := skipRound(guildId, status.Round)
+	if err != nil {
+		return status, err
+	}
+
+	status.Passed = true
+	return status, nil
+}
+
+// SkipRound skips the current round right away, without a vote nor cooldown
+func SkipRound(guildId int) error {
+	return skipRound(guildId, 0)
+}
+
+// skipRound replaces the round number with a new one, revealing the price
+// of the skipped ad. A number of 0 skips whatever round is going on
+func skipRound(guildId int, number int) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+
+	if number != 0 && gi.round.number != number {
+		gi.mu.Unlock()
+		return ErrNoSkipVote
+	}
+
+	// the round might have been won while the vote went on, or be on its way
+	// to open. Rounds with an ad that never opened nor ended are left over
+	// from a restart during their announcement, and skipping them is the way out
+	leftOver := gi.round.ad != nil && gi.round.endedAt.IsZero() && !gi.round.announcing
+	if !gi.round.open && !leftOver {
+		gi.mu.Unlock()
+		return ErrRoundClosed
+	}
+
+	if gi.round.ad == nil {
+		gi.mu.Unlock()
+		return ErrNoAd
+	}
+
+	skipped := RoundSkipped{
+		GuildId: guildId,
+		Thread:  gi.round.threadId,
+		Number:  gi.round.number,
+		Ad:      *gi.round.ad,
+	}
+
+	err := gi.newRound()
+	if err != nil {
+		gi.mu.Unlock()
+		return err
+	}
+
+	gi.lastSkip = time.Now()
+	gi.skipVote = nil
+	gi.mu.Unlock()
+
+	bus.Publish(skipped)
+	return announceRound(guildId)
+}
+
+// SetSkipVotes changes how many votes skipping a round takes
+func SetSkipVotes(guildId int, votes int) error {
+	if votes < 1 || votes > MaxSkipVotes {
+		return ErrInvalidSkipVotes
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.skipVotes = votes
+	return gi.saveSettings()
+}
+
+func SkipVotes(guildId int) int {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return DefaultSkipVotes
+	}
+	defer gi.mu.Unlock()
+
+	return gi.votesNeeded()
+}
@@ -0,0 +1,234 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestSkipVote(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+		{Id: 2, Title: "Poltrona em tecido", Price: 250},
+		{Id: 3, Title: "iPhone XR 64Gb - Preto", Price: 850},
+	}, guildId)
+
+	err := SetSkipVotes(guildId, 2)
+	if err != nil {
+		t.Fatalf("setting skip votes: %v\n", err)
+	}
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	number := RoundNumber(guildId)
+	ad := Ad(guildId)
+
+	var happened []Event
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Guild() == guildId {
+			happened = append(happened, e)
+		}
+	})
+	defer unsubscribe()
+
+	vote, err := StartSkipVote(guildId, "ana")
+	if err != nil {
+		t.Fatalf("starting skip vote: %v\n", err)
+	}
+
+	expected := SkipVote{Round: number, Votes: 1, Needed: 2}
+	if vote != expected {
+		t.Fatalf("vote mismatch\n  Want: %v\n  Got: %v\n", expected, vote)
+	}
+
+	_, err = StartSkipVote(guildId, "bia")
+	if err != ErrSkipVoteOpen {
+		t.Fatalf("second vote was started\n  Want: %v\n  Got: %v\n", ErrSkipVoteOpen, err)
+	}
+
+	_, err = VoteSkip(guildId, "ana", number)
+	if err != ErrAlreadyVoted {
+		t.Fatalf("user voted twice\n  Want: %v\n  Got: %v\n", ErrAlreadyVoted, err)
+	}
+
+	_, err = VoteSkip(guildId, "bia", number+1)
+	if err != ErrNoSkipVote {
+		t.Fatalf("vote for another round was counted\n  Want: %v\n  Got: %v\n", ErrNoSkipVote, err)
+	}
+
+	vote, err = VoteSkip(guildId, "bia", number)
+	if err != nil || !vote.Passed {
+		t.Fatalf("vote didn't pass with enough votes: %v %v\n", vote, err)
+	}
+
+	if RoundNumber(guildId) != number+1 {
+		t.Fatalf("round wasn't skipped\n  Want: %d\n  Got: %d\n", number+1, RoundNumber(guildId))
+	}
+
+	// the skipped ad is revealed before the next round starts
+	if len(happened) < 2 {
+		t.Fatalf("skip wasn't published: %v\n", happened)
+	}
+
+	skipped, ok := happened[0].(RoundSkipped)
+	if !ok || skipped.Number != number || skipped.Ad != ad {
+		t.Fatalf("skipped round mismatch\n  Want: round %d with %v\n  Got: %v\n", number, ad, happened[0])
+	}
+
+	if _, ok := happened[1].(RoundStarted); !ok {
+		t.Fatalf("next round wasn't started after the skip: %v\n", happened[1])
+	}
+
+	_, err = StartSkipVote(guildId, "caio")
+	if err != ErrSkipCooldown {
+		t.Fatalf("vote was started during the cooldown\n  Want: %v\n  Got: %v\n", ErrSkipCooldown, err)
+	}
+
+	if left := SkipCooldownLeft(guildId); left <= 0 || left > SkipCooldown {
+		t.Fatalf("cooldown left out of range: %v\n", left)
+	}
+
+	// admins skip during the cooldown
+	err = SkipRound(guildId)
+	if err != nil {
+		t.Fatalf("skipping round: %v\n", err)
+	}
+
+	if RoundNumber(guildId) != number+2 {
+		t.Fatalf("round wasn't skipped\n  Want: %d\n  Got: %d\n", number+2, RoundNumber(guildId))
+	}
+}
+
+func TestSkipVoteExpires(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	_, err = StartSkipVote(guildId, "ana")
+	if err != nil {
+		t.Fatalf("starting skip vote: %v\n", err)
+	}
+
+	gi, _ := lockInstance(guildId)
+	gi.skipVote.startedAt = time.Now().Add(-SkipVoteDuration - time.Second)
+	gi.mu.Unlock()
+
+	_, err = VoteSkip(guildId, "bia", RoundNumber(guildId))
+	if err != ErrNoSkipVote {
+		t.Fatalf("expired vote was counted\n  Want: %v\n  Got: %v\n", ErrNoSkipVote, err)
+	}
+
+	vote, err := StartSkipVote(guildId, "bia")
+	if err != nil || vote.Votes != 1 || vote.Needed != DefaultSkipVotes {
+		t.Fatalf("new vote mismatch after the last one expired: %v %v\n", vote, err)
+	}
+}
+
+func TestSkipClosedRound(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	var happened []Event
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Guild() == guildId {
+			happened = append(happened, e)
+		}
+	})
+	defer unsubscribe()
+
+	err := SkipRound(guildId)
+	if err != ErrRoundClosed {
+		t.Fatalf("closed round was skipped\n  Want: %v\n  Got: %v\n", ErrRoundClosed, err)
+	}
+
+	if len(happened) != 0 {
+		t.Fatalf("skipping a closed round published events: %v\n", happened)
+	}
+}
+
+func TestSkipRoundLeftClosedByRestart(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+		{Id: 2, Title: "Poltrona em tecido", Price: 250},
+	}, guildId)
+
+	// the bot restarts after creating the round, before opening it
+	err := NewRound(guildId)
+	if err != nil {
+		t.Fatalf("creating round: %v\n", err)
+	}
+
+	err = LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("loading guilds: %v\n", err)
+	}
+
+	number := RoundNumber(guildId)
+	_, err = CheckGuess(0, "ana", 1200, guildId)
+	if err != ErrRoundClosed {
+		t.Fatalf("guess in a round that never opened was accepted\n  Want: %v\n  Got: %v\n", ErrRoundClosed, err)
+	}
+
+	err = SkipRound(guildId)
+	if err != nil {
+		t.Fatalf("skipping round left closed by a restart: %v\n", err)
+	}
+
+	if RoundNumber(guildId) != number+1 {
+		t.Fatalf("round wasn't skipped\n  Want: %d\n  Got: %d\n", number+1, RoundNumber(guildId))
+	}
+
+	_, err = CheckGuess(0, "ana", 250, guildId)
+	if err != nil {
+		t.Fatalf("next round didn't open: %v\n", err)
+	}
+}
+
+func TestSQLStoreSkipVotes(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	for _, invalid := range []int{0, MaxSkipVotes + 1} {
+		err := SetSkipVotes(guildId, invalid)
+		if err != ErrInvalidSkipVotes {
+			t.Fatalf("invalid skip votes %d were accepted\n  Want: %v\n  Got: %v\n", invalid, ErrInvalidSkipVotes, err)
+		}
+	}
+
+	err := SetSkipVotes(guildId, 5)
+	if err != nil {
+		t.Fatalf("setting skip votes: %v\n", err)
+	}
+
+	guilds, err := store.Guilds()
+	if err != nil {
+		t.Fatalf("fetching guilds: %v\n", err)
+	}
+
+	for _, g := range guilds {
+		expected := DefaultSkipVotes
+		if g.GuildId == guildId {
+			expected = 5
+		}
+
+		if g.SkipVotes != expected {
+			t.Fatalf("skip votes of guild %d mismatch\n  Want: %d\n  Got: %d\n", g.GuildId, expected, g.SkipVotes)
+		}
+	}
+}
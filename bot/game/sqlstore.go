@@ -0,0 +1,1371 @@
+package game
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+type SQLStore struct {
+	conn *sql.DB
+}
+
+func NewSQLStore(conn *sql.DB) *SQLStore {
+	return &SQLStore{conn: conn}
+}
+
+func (s *SQLStore) Guilds() ([]GuildSettings, error) {
+	rows, err := s.conn.Query(`
+		SELECT g.discord_id, g.game_channel_id, g.round_timeout, g.game_mode, g.max_guesses, g.min_price, g.max_price,
+			g.teams_enabled, g.parent_id, g.separate_ranking, g.round_threads, g.win_tolerance, g.win_tolerance_amount,
+			g.skip_votes
+		FROM guilds g;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []GuildSettings
+	for rows.Next() {
+		var (
+			settings        GuildSettings
+			game_channel_id sql.NullInt64
+			parent_id       sql.NullInt64
+			round_timeout   int
+		)
+		err := rows.Scan(&settings.GuildId, &game_channel_id, &round_timeout, &settings.Mode, &settings.MaxGuesses,
+			&settings.PriceRange.Min, &settings.PriceRange.Max, &settings.TeamsEnabled, &parent_id, &settings.SeparateRanking,
+			&settings.RoundThreads, &settings.Tolerance.Kind, &settings.Tolerance.Amount,
+			&settings.SkipVotes)
+		if err != nil {
+			return nil, err
+		}
+
+		settings.ChannelId = int(game_channel_id.Int64)
+		settings.ParentId = int(parent_id.Int64)
+		settings.RoundTimeout = time.Duration(round_timeout) * time.Minute
+		res = append(res, settings)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.conn.Query(`
+		SELECT guild_id, kind, guesses, every, elapsed, chance
+		FROM hint_rules
+		ORDER BY guild_id, kind
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rules.Close()
+
+	positions := make(map[int]int)
+	for idx, settings := range res {
+		positions[settings.GuildId] = idx
+	}
+
+	for rules.Next() {
+		var (
+			guildId int
+			rule    HintRule
+			elapsed int
+		)
+		err := rules.Scan(&guildId, &rule.Kind, &rule.Guesses, &rule.Every, &elapsed, &rule.Chance)
+		if err != nil {
+			return nil, err
+		}
+
+		pos, ok := positions[guildId]
+		if !ok {
+			continue
+		}
+
+		rule.Elapsed = time.Duration(elapsed) * time.Second
+		res[pos].HintRules = append(res[pos].HintRules, rule)
+	}
+
+	return res, rules.Err()
+}
+
+func (s *SQLStore) AddGuild(guildId int) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO guilds(discord_id)
+		VALUES (?)
+		ON CONFLICT do nothing;
+	`, guildId)
+
+	return err
+}
+
+func (s *SQLStore) SaveGuild(settings GuildSettings) error {
+	channelId := sql.NullInt64{Int64: int64(settings.ChannelId), Valid: settings.ChannelId != 0}
+	parentId := sql.NullInt64{Int64: int64(settings.ParentId), Valid: settings.ParentId != 0}
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE guilds
+		SET game_channel_id = ?, round_timeout = ?, game_mode = ?, max_guesses = ?, min_price = ?, max_price = ?,
+			teams_enabled = ?, parent_id = ?, separate_ranking = ?, round_threads = ?, win_tolerance = ?, win_tolerance_amount = ?,
+			skip_votes = ?
+		WHERE discord_id = ?
+	`, channelId, int(settings.RoundTimeout/time.Minute), settings.Mode, settings.MaxGuesses,
+		settings.PriceRange.Min, settings.PriceRange.Max, settings.TeamsEnabled, parentId, settings.SeparateRanking,
+		settings.RoundThreads, settings.Tolerance.Kind, settings.Tolerance.Amount, settings.SkipVotes, settings.GuildId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM hint_rules WHERE guild_id = ?`, settings.GuildId)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range settings.HintRules {
+		_, err = tx.Exec(`
+			INSERT INTO hint_rules (guild_id, kind, guesses, every, elapsed, chance)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, settings.GuildId, rule.Kind, rule.Guesses, rule.Every, int(rule.Elapsed/time.Second), rule.Chance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) RemoveGuild(guildId int) error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE rounds
+		SET ended_at = ?, end_reason = ?, opened = 0
+		WHERE guild_id = ? AND ended_at IS NULL
+	`, time.Now().Unix(), EndReasonSkipped, guildId)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM hint_rules WHERE guild_id = ?`,
+		`DELETE FROM disabled_categories WHERE guild_id = ?`,
+		`DELETE FROM seen_ads WHERE guild_id = ?`,
+		`DELETE FROM guilds WHERE discord_id = ?`,
+	} {
+		_, err = tx.Exec(query, guildId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) CurrentRounds() ([]RoundState, error) {
+	rows, err := s.conn.Query(`
+		SELECT
+			r.guild_id, r.id,
+			ad.id, ad.title, ad.image, ad.price, ad.location, ad.category,
+			r.started_at, r.opened, r.number, r.guess_count, r.hints, r.same_price,
+			r.closest_username, r.closest_guess, r.thread_id
+		FROM rounds r
+		JOIN olx_ads ad ON r.ad_id = ad.id
+		WHERE r.ended_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []RoundState
+	positions := make(map[int]int)
+
+	for rows.Next() {
+		var (
+			round           RoundState
+			category        sql.NullString
+			started_at      sql.NullInt64
+			hints           string
+			same_price      string
+			closestUsername sql.NullString
+			closestGuess    sql.NullInt64
+			threadId        sql.NullInt64
+		)
+		err := rows.Scan(&round.GuildId, &round.Id,
+			&round.Ad.Id, &round.Ad.Title, &round.Ad.Image, &round.Ad.Price, &round.Ad.Location, &category,
+			&started_at, &round.Open, &round.Number, &round.GuessCount, &hints, &same_price,
+			&closestUsername, &closestGuess, &threadId)
+		if err != nil {
+			return nil, err
+		}
+
+		round.Ad.Category = category.String
+		round.ThreadId = int(threadId.Int64)
+
+		if hints != "" {
+			round.Hints = strings.Split(hints, ",")
+		}
+
+		if same_price != "" {
+			for _, id := range strings.Split(same_price, ",") {
+				adId, err := strconv.Atoi(id)
+				if err != nil {
+					return nil, err
+				}
+
+				round.SamePrice = append(round.SamePrice, adId)
+			}
+		}
+
+		if closestUsername.Valid {
+			round.ClosestGuess = &ClosestGuessHint{
+				Username: closestUsername.String,
+				Guess:    int(closestGuess.Int64),
+			}
+		}
+
+		// rounds created before timed rounds existed count from now on
+		if started_at.Valid {
+			round.StartedAt = time.Unix(started_at.Int64, 0)
+		} else {
+			round.StartedAt = time.Now()
+		}
+
+		positions[round.Id] = len(res)
+		res = append(res, round)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	guesses, err := s.conn.Query(`
+		SELECT g.id, g.guild_id, g.round_id, g.value, g.username, g.user_id, g.created_at
+		FROM guesses g
+		JOIN rounds r ON r.id = g.round_id
+		WHERE r.ended_at IS NULL
+		ORDER BY g.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer guesses.Close()
+
+	for guesses.Next() {
+		g, err := scanGuess(guesses)
+		if err != nil {
+			return nil, err
+		}
+
+		pos, ok := positions[g.RoundId]
+		if !ok {
+			continue
+		}
+
+		res[pos].Guesses = append(res[pos].Guesses, g)
+	}
+
+	if err = guesses.Err(); err != nil {
+		return nil, err
+	}
+
+	bets, err := s.conn.Query(`
+		SELECT b.id, b.guild_id, b.round_id, b.username, b.guess, b.stake, b.payout
+		FROM bets b
+		JOIN rounds r ON r.id = b.round_id
+		WHERE r.ended_at IS NULL
+		ORDER BY b.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer bets.Close()
+
+	for bets.Next() {
+		var (
+			b      Bet
+			payout sql.NullInt64
+		)
+		err := bets.Scan(&b.Id, &b.GuildId, &b.RoundId, &b.Username, &b.Guess, &b.Stake, &payout)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Payout = int(payout.Int64)
+		b.Settled = payout.Valid
+
+		pos, ok := positions[b.RoundId]
+		if !ok {
+			continue
+		}
+
+		res[pos].Bets = append(res[pos].Bets, b)
+	}
+
+	return res, bets.Err()
+}
+
+func scanGuess(rows *sql.Rows) (Guess, error) {
+	var (
+		g         Guess
+		userId    sql.NullInt64
+		createdAt sql.NullInt64
+	)
+
+	err := rows.Scan(&g.Id, &g.GuildId, &g.RoundId, &g.Value, &g.Username, &userId, &createdAt)
+	if err != nil {
+		return g, err
+	}
+
+	g.UserId = int(userId.Int64)
+
+	if createdAt.Valid {
+		g.CreatedAt = time.Unix(createdAt.Int64, 0)
+	}
+
+	return g, nil
+}
+
+func (s *SQLStore) StartRound(guildId int, adId int, startedAt time.Time) (int, int, error) {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer tx.Rollback()
+
+	// rounds that didn't end by themselves were skipped
+	_, err = tx.Exec(`
+		UPDATE rounds
+		SET ended_at = ?, end_reason = ?, opened = 0
+		WHERE guild_id = ? AND ended_at IS NULL
+	`, startedAt.Unix(), EndReasonSkipped, guildId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var number int
+	err = tx.QueryRow(`
+		SELECT COALESCE(MAX(number), 0) + 1
+		FROM rounds
+		WHERE guild_id = ?
+	`, guildId).Scan(&number)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO rounds (guild_id, ad_id, started_at, number)
+		VALUES (?, ?, ?, ?)
+	`, guildId, adId, startedAt.Unix(), number)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	roundId, err := res.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO seen_ads (guild_id, ad_id)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+	`, guildId, adId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(roundId), number, tx.Commit()
+}
+
+// SaveRound leaves the guess count out because it's incremented by AddGuess
+func (s *SQLStore) SaveRound(round RoundState) error {
+	samePrice := make([]string, len(round.SamePrice))
+	for i, id := range round.SamePrice {
+		samePrice[i] = strconv.Itoa(id)
+	}
+
+	var closestUsername sql.NullString
+	var closestGuess sql.NullInt64
+	if round.ClosestGuess != nil {
+		closestUsername = sql.NullString{String: round.ClosestGuess.Username, Valid: true}
+		closestGuess = sql.NullInt64{Int64: int64(round.ClosestGuess.Guess), Valid: true}
+	}
+
+	threadId := sql.NullInt64{Int64: int64(round.ThreadId), Valid: round.ThreadId != 0}
+
+	var endedAt sql.NullInt64
+	var endReason, winner sql.NullString
+	if !round.EndedAt.IsZero() {
+		endedAt = sql.NullInt64{Int64: round.EndedAt.Unix(), Valid: true}
+		endReason = sql.NullString{String: string(round.EndReason), Valid: true}
+		winner = sql.NullString{String: round.Winner, Valid: round.Winner != ""}
+	}
+
+	_, err := s.conn.Exec(`
+		UPDATE rounds
+		SET opened = ?, hints = ?, same_price = ?, closest_username = ?, closest_guess = ?,
+			ended_at = ?, end_reason = ?, winner = ?, thread_id = ?
+		WHERE id = ?`,
+		round.Open, strings.Join(round.Hints, ","), strings.Join(samePrice, ","),
+		closestUsername, closestGuess, endedAt, endReason, winner, threadId, round.Id)
+
+	return err
+}
+
+func (s *SQLStore) FinishedRoundsCount(guildId int) (int, error) {
+	var count int
+	err := s.conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM rounds
+		WHERE guild_id = ? AND ended_at IS NOT NULL`, guildId).Scan(&count)
+
+	return count, err
+}
+
+func (s *SQLStore) FinishedRound(guildId int, offset int) (ArchivedRound, error) {
+	var (
+		res       ArchivedRound
+		category  sql.NullString
+		startedAt sql.NullInt64
+		endedAt   int64
+		winner    sql.NullString
+	)
+
+	err := s.conn.QueryRow(`
+		SELECT
+			r.id, r.number, r.started_at, r.ended_at, r.end_reason, r.winner, r.guess_count,
+			ad.id, ad.title, ad.image, ad.price, ad.location, ad.category
+		FROM rounds r
+		JOIN olx_ads ad ON ad.id = r.ad_id
+		WHERE r.guild_id = ? AND r.ended_at IS NOT NULL
+		ORDER BY r.ended_at DESC, r.id DESC
+		LIMIT 1 OFFSET ?`, guildId, offset).Scan(
+		&res.Id, &res.Number, &startedAt, &endedAt, &res.EndReason, &winner, &res.GuessCount,
+		&res.Ad.Id, &res.Ad.Title, &res.Ad.Image, &res.Ad.Price, &res.Ad.Location, &category)
+	if err != nil {
+		return res, err
+	}
+
+	if startedAt.Valid {
+		res.StartedAt = time.Unix(startedAt.Int64, 0)
+	}
+	res.EndedAt = time.Unix(endedAt, 0)
+	res.Winner = winner.String
+	res.Ad.Category = category.String
+
+	rows, err := s.conn.Query(`
+		SELECT id, guild_id, round_id, value, username, user_id, created_at
+		FROM guesses
+		WHERE round_id = ?
+		ORDER BY id`, res.Id)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g, err := scanGuess(rows)
+		if err != nil {
+			return res, err
+		}
+
+		res.Guesses = append(res.Guesses, g)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *SQLStore) AddGuess(guess Guess) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO guesses(guild_id, round_id, value, username, user_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		guess.GuildId, guess.RoundId, guess.Value, guess.Username,
+		sql.NullInt64{Int64: int64(guess.UserId), Valid: guess.UserId != 0}, guess.CreatedAt.Unix())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Exec(`
+		UPDATE rounds
+		SET guess_count = guess_count + 1
+		WHERE id = ?`, guess.RoundId)
+
+	return err
+}
+
+func (s *SQLStore) Ratings(guildId int) ([]Rating, error) {
+	rows, err := s.conn.Query(`
+		SELECT username, rating, rounds
+		FROM ratings
+		WHERE guild_id = ?
+		ORDER BY rating DESC, username`, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ratings []Rating
+	for rows.Next() {
+		var r Rating
+		err = rows.Scan(&r.Username, &r.Rating, &r.Rounds)
+		if err != nil {
+			return nil, err
+		}
+
+		ratings = append(ratings, r)
+	}
+
+	return ratings, rows.Err()
+}
+
+func (s *SQLStore) SaveRatings(guildId int, ratings map[string]float64) error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for username, rating := range ratings {
+		_, err = tx.Exec(`
+			INSERT INTO ratings (guild_id, username, rating, rounds)
+			VALUES (?, ?, ?, 1)
+			ON CONFLICT (guild_id, username) DO UPDATE
+			SET rating = excluded.rating, rounds = rounds + 1
+		`, guildId, username, rating)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) AddScore(guildId int, username string, points int) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO scores (username, guild_id, points)
+		VALUES (?, ?, ?)`, username, guildId, points)
+
+	return err
+}
+
+func (s *SQLStore) Ranking(guildId int) ([]AggregatedScore, error) {
+	rows, err := s.conn.Query(`
+		SELECT username, SUM(points) as score
+		FROM scores
+		WHERE guild_id = ?
+		GROUP BY username
+		ORDER BY SUM(points) DESC`, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []AggregatedScore
+	for rows.Next() {
+		sc := AggregatedScore{}
+		err = rows.Scan(&sc.Username, &sc.Score)
+		if err != nil {
+			return nil, err
+		}
+
+		scores = append(scores, sc)
+	}
+
+	return scores, rows.Err()
+}
+
+func balance(tx interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, guildId int, username string) (int, error) {
+	var res int
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0)
+		FROM coin_ledger
+		WHERE guild_id = ? AND username = ?`, guildId, username).Scan(&res)
+
+	return res, err
+}
+
+func (s *SQLStore) PlaceBet(bet Bet) (Bet, error) {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return bet, err
+	}
+
+	defer tx.Rollback()
+
+	coins, err := balance(tx, bet.GuildId, bet.Username)
+	if err != nil {
+		return bet, err
+	}
+
+	if coins < bet.Stake {
+		return bet, ErrNotEnoughCoins
+	}
+
+	now := time.Now().Unix()
+	res, err := tx.Exec(`
+		INSERT INTO bets (guild_id, round_id, username, guess, stake, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, bet.GuildId, bet.RoundId, bet.Username, bet.Guess, bet.Stake, now)
+	if err != nil {
+		return bet, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return bet, err
+	}
+	bet.Id = int(id)
+
+	_, err = tx.Exec(`
+		INSERT INTO coin_ledger (guild_id, username, amount, reason, bet_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, bet.GuildId, bet.Username, -bet.Stake, LedgerBet, bet.Id, now)
+	if err != nil {
+		return bet, err
+	}
+
+	return bet, tx.Commit()
+}
+
+func (s *SQLStore) SettleBets(bets []Bet, reason LedgerReason) error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	for _, bet := range bets {
+		_, err = tx.Exec(`UPDATE bets SET payout = ? WHERE id = ?`, bet.Payout, bet.Id)
+		if err != nil {
+			return err
+		}
+
+		if bet.Payout == 0 {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO coin_ledger (guild_id, username, amount, reason, bet_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, bet.GuildId, bet.Username, bet.Payout, reason, bet.Id, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) Balance(guildId int, username string) (int, error) {
+	return balance(s.conn, guildId, username)
+}
+
+func (s *SQLStore) Ledger(guildId int, username string, limit int) ([]LedgerEntry, error) {
+	rows, err := s.conn.Query(`
+		SELECT amount, reason, bet_id, created_at
+		FROM coin_ledger
+		WHERE guild_id = ? AND username = ?
+		ORDER BY id DESC
+		LIMIT ?`, guildId, username, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var (
+			e         LedgerEntry
+			betId     sql.NullInt64
+			createdAt int64
+		)
+		err := rows.Scan(&e.Amount, &e.Reason, &betId, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		e.BetId = int(betId.Int64)
+		e.CreatedAt = time.Unix(createdAt, 0)
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *SQLStore) ClaimDaily(guildId int, username string, amount int, since time.Time) (bool, error) {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer tx.Rollback()
+
+	var claims int
+	err = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM coin_ledger
+		WHERE guild_id = ? AND username = ? AND reason = ? AND created_at >= ?
+	`, guildId, username, LedgerDaily, since.Unix()).Scan(&claims)
+	if err != nil {
+		return false, err
+	}
+
+	if claims > 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO coin_ledger (guild_id, username, amount, reason, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, guildId, username, amount, LedgerDaily, time.Now().Unix())
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (s *SQLStore) AddTeam(guildId int, name string, roleId int) (Team, error) {
+	role := sql.NullInt64{Int64: int64(roleId), Valid: roleId != 0}
+
+	res, err := s.conn.Exec(`
+		INSERT INTO teams (guild_id, name, role_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, guildId, name, role)
+	if err != nil {
+		return Team{}, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return Team{}, err
+	}
+
+	if affected == 0 {
+		return Team{}, ErrTeamExists
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Team{}, err
+	}
+
+	return Team{Id: int(id), Name: name, RoleId: roleId}, nil
+}
+
+func scanTeam(row interface{ Scan(...any) error }) (Team, error) {
+	var (
+		t    Team
+		role sql.NullInt64
+	)
+
+	err := row.Scan(&t.Id, &t.Name, &role)
+	t.RoleId = int(role.Int64)
+
+	return t, err
+}
+
+func (s *SQLStore) Teams(guildId int) ([]Team, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, name, role_id
+		FROM teams
+		WHERE guild_id = ?
+		ORDER BY id`, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		t, err := scanTeam(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, t)
+	}
+
+	return teams, rows.Err()
+}
+
+func (s *SQLStore) SetTeamMember(guildId int, username string, teamId int) error {
+	if teamId == 0 {
+		_, err := s.conn.Exec(`
+			DELETE FROM team_members
+			WHERE guild_id = ? AND username = ?`, guildId, username)
+
+		return err
+	}
+
+	_, err := s.conn.Exec(`
+		INSERT INTO team_members (guild_id, username, team_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT (guild_id, username) DO UPDATE SET team_id = excluded.team_id
+	`, guildId, username, teamId)
+
+	return err
+}
+
+func (s *SQLStore) TeamOf(guildId int, username string) (Team, error) {
+	row := s.conn.QueryRow(`
+		SELECT t.id, t.name, t.role_id
+		FROM team_members m
+		JOIN teams t ON t.id = m.team_id
+		WHERE m.guild_id = ? AND m.username = ?`, guildId, username)
+
+	t, err := scanTeam(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrNoTeam
+	}
+
+	return t, err
+}
+
+func (s *SQLStore) AddTeamScore(guildId int, teamId int, points int) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO team_scores (guild_id, team_id, points)
+		VALUES (?, ?, ?)`, guildId, teamId, points)
+
+	return err
+}
+
+func (s *SQLStore) TeamRanking(guildId int) ([]TeamScore, error) {
+	rows, err := s.conn.Query(`
+		SELECT t.name, SUM(sc.points) as score
+		FROM team_scores sc
+		JOIN teams t ON t.id = sc.team_id
+		WHERE sc.guild_id = ?
+		GROUP BY t.id
+		ORDER BY SUM(sc.points) DESC`, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []TeamScore
+	for rows.Next() {
+		sc := TeamScore{}
+		err = rows.Scan(&sc.Team, &sc.Score)
+		if err != nil {
+			return nil, err
+		}
+
+		scores = append(scores, sc)
+	}
+
+	return scores, rows.Err()
+}
+
+// eligibleAds filters the ads a guild can get in a round: not seen yet,
+// within prices and from a category it didn't disable. Prices are cast
+// since they're stored as text. Its arguments come from eligibleAdsArgs
+const eligibleAds = `
+	ads.category NOT IN (
+		SELECT category
+		FROM disabled_categories
+		WHERE guild_id = ?
+	)
+	AND CAST(ads.price AS INTEGER) >= ?
+	AND (? = 0 OR CAST(ads.price AS INTEGER) <= ?)
+	AND NOT EXISTS (
+		SELECT 1
+		FROM seen_ads seen
+		WHERE seen.guild_id = ? AND seen.ad_id = ads.id
+	)`
+
+func eligibleAdsArgs(guildId int, prices PriceRange) []any {
+	return []any{guildId, prices.Min, prices.Max, prices.Max, guildId}
+}
+
+// randomAdCandidates is how many random ids RandomAd looks up in a single
+// query. Tests lower it to keep the queries cheap
+var randomAdCandidates = 512
+
+const (
+	// randomAdBatches is how many queries of candidates RandomAd makes before
+	// going through every eligible ad, which only pays off for guilds that
+	// can get very few of the ads
+	randomAdBatches = 4
+	// eligibleAdsPage is how many ads RandomAd reads at a time when it goes
+	// through every eligible ad
+	eligibleAdsPage = 1000
+)
+
+// RandomAd picks one of the ads the guild can get, every one of them just as
+// likely. It first looks up batches of random ids, keeping one of those that
+// are eligible. When none are, it goes through the eligible ads in pages by
+// id, picking one as it goes
+func (s *SQLStore) RandomAd(guildId int, prices PriceRange) (olx.OLXAd, error) {
+	// separate subqueries let both bounds come from the index, instead of a scan
+	var minId, maxId sql.NullInt64
+	err := s.conn.QueryRow(`
+		SELECT (SELECT MIN(id) FROM olx_ads), (SELECT MAX(id) FROM olx_ads)
+	`).Scan(&minId, &maxId)
+	if err != nil {
+		return olx.OLXAd{}, err
+	}
+
+	if !minId.Valid {
+		return olx.OLXAd{}, ErrNoAds
+	}
+
+	for range randomAdBatches {
+		ads, err := s.candidateAds(guildId, prices, minId.Int64, maxId.Int64)
+		if err != nil {
+			return olx.OLXAd{}, err
+		}
+
+		// every eligible ad is just as likely to be among the candidates
+		if len(ads) > 0 {
+			return ads[rand.N(len(ads))], nil
+		}
+	}
+
+	return s.sampleEligibleAds(guildId, prices, minId.Int64-1)
+}
+
+// candidateAds looks up randomAdCandidates random ids between minId and maxId,
+// returning the ads among them the guild can get
+func (s *SQLStore) candidateAds(guildId int, prices PriceRange, minId int64, maxId int64) ([]olx.OLXAd, error) {
+	args := make([]any, 0, randomAdCandidates+5)
+	for range randomAdCandidates {
+		args = append(args, minId+rand.N(maxId-minId+1))
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", randomAdCandidates), ", ")
+	return s.eligibleAdRows(`ads.id IN (`+placeholders+`) AND `+eligibleAds, "", append(args, eligibleAdsArgs(guildId, prices)...)...)
+}
+
+// sampleEligibleAds goes through the ads the guild can get with ids after
+// afterId, one page at a time, keeping each with a chance of one over how
+// many were seen so far. That leaves every ad just as likely to be picked
+func (s *SQLStore) sampleEligibleAds(guildId int, prices PriceRange, afterId int64) (olx.OLXAd, error) {
+	var (
+		picked olx.OLXAd
+		seen   int
+	)
+
+	for {
+		args := append([]any{afterId}, eligibleAdsArgs(guildId, prices)...)
+		page, err := s.eligibleAdRows(`ads.id > ? AND `+eligibleAds, "ORDER BY ads.id LIMIT ?", append(args, eligibleAdsPage)...)
+		if err != nil {
+			return olx.OLXAd{}, err
+		}
+
+		for _, ad := range page {
+			seen++
+			if rand.N(seen) == 0 {
+				picked = ad
+			}
+		}
+
+		if len(page) < eligibleAdsPage {
+			break
+		}
+		afterId = int64(page[len(page)-1].Id)
+	}
+
+	if seen == 0 {
+		return olx.OLXAd{}, ErrNoAds
+	}
+
+	return picked, nil
+}
+
+// eligibleAdRows gets the ads matching where, in the order suffix gives them
+func (s *SQLStore) eligibleAdRows(where string, suffix string, args ...any) ([]olx.OLXAd, error) {
+	rows, err := s.conn.Query(`
+		SELECT
+			ads.id,
+			ads.title,
+			ads.image,
+			ads.price,
+			ads.location,
+			ads.category
+		FROM
+			olx_ads ads
+		WHERE `+where+`
+		`+suffix, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ads []olx.OLXAd
+	for rows.Next() {
+		var (
+			ad       olx.OLXAd
+			category sql.NullString
+		)
+
+		err = rows.Scan(&ad.Id, &ad.Title, &ad.Image, &ad.Price, &ad.Location, &category)
+		if err != nil {
+			return nil, err
+		}
+
+		ad.Category = category.String
+		ads = append(ads, ad)
+	}
+
+	return ads, rows.Err()
+}
+
+func (s *SQLStore) Challenge(day time.Time) (olx.OLXAd, error) {
+	var (
+		ad       olx.OLXAd
+		category sql.NullString
+	)
+
+	err := s.conn.QueryRow(`
+		SELECT ads.id, ads.title, ads.image, ads.price, ads.location, ads.category
+		FROM daily_challenges c
+		JOIN olx_ads ads ON ads.id = c.ad_id
+		WHERE c.day = ?
+	`, day.Format(time.DateOnly)).Scan(&ad.Id, &ad.Title, &ad.Image, &ad.Price, &ad.Location, &category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ad, ErrNoChallenge
+	}
+
+	ad.Category = category.String
+	return ad, err
+}
+
+func (s *SQLStore) StartChallenge(day time.Time, adId int) error {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO daily_challenges (day, ad_id)
+		VALUES (?, ?)
+	`, day.Format(time.DateOnly), adId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO seen_ads (guild_id, ad_id)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+	`, challengeGuild, adId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) AddChallengeAttempt(attempt ChallengeAttempt) error {
+	_, err := s.conn.Exec(`
+		INSERT INTO challenge_attempts (day, guild_id, username, guess, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, attempt.Day.Format(time.DateOnly), attempt.GuildId, attempt.Username, attempt.Guess, attempt.CreatedAt.Unix())
+
+	return err
+}
+
+func (s *SQLStore) ChallengeAttempts(day time.Time) ([]ChallengeAttempt, error) {
+	rows, err := s.conn.Query(`
+		SELECT guild_id, username, guess, created_at
+		FROM challenge_attempts
+		WHERE day = ?
+		ORDER BY id
+	`, day.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []ChallengeAttempt
+	for rows.Next() {
+		a := ChallengeAttempt{Day: day}
+
+		var createdAt int64
+		err := rows.Scan(&a.GuildId, &a.Username, &a.Guess, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		a.CreatedAt = time.Unix(createdAt, 0)
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+func (s *SQLStore) StartDuel(duel Duel) (int, error) {
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO duels (guild_id, challenger, opponent, ad_id, started_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, duel.GuildId, duel.Challenger, duel.Opponent, duel.Ad.Id, duel.StartedAt.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO seen_ads (guild_id, ad_id)
+		VALUES (?, ?)
+		ON CONFLICT DO NOTHING
+	`, duel.GuildId, duel.Ad.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+func (s *SQLStore) FinishDuel(duel Duel) error {
+	var challengerGuess, opponentGuess sql.NullInt64
+	if g, ok := BestDuelGuess(duel.ChallengerGuesses, duel.Ad.Price); ok {
+		challengerGuess = sql.NullInt64{Int64: int64(g), Valid: true}
+	}
+
+	if g, ok := BestDuelGuess(duel.OpponentGuesses, duel.Ad.Price); ok {
+		opponentGuess = sql.NullInt64{Int64: int64(g), Valid: true}
+	}
+
+	winner := sql.NullString{String: duel.Winner, Valid: duel.Winner != ""}
+
+	_, err := s.conn.Exec(`
+		UPDATE duels
+		SET challenger_guess = ?, opponent_guess = ?, winner = ?, ended_at = ?
+		WHERE id = ?
+	`, challengerGuess, opponentGuess, winner, duel.EndedAt.Unix(), duel.Id)
+
+	return err
+}
+
+func (s *SQLStore) DuelRecord(guildId int, username string) (DuelRecord, error) {
+	var record DuelRecord
+	err := s.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(winner = ?), 0),
+			COALESCE(SUM(winner IS NOT NULL AND winner != ?), 0),
+			COALESCE(SUM(winner IS NULL), 0)
+		FROM duels
+		WHERE guild_id = ? AND ended_at IS NOT NULL AND (challenger = ? OR opponent = ?)
+	`, username, username, guildId, username, username).Scan(&record.Wins, &record.Losses, &record.Draws)
+
+	return record, err
+}
+
+func (s *SQLStore) WinsByCategory(guildId int, username string) (map[string]int, error) {
+	rows, err := s.conn.Query(`
+		SELECT COALESCE(ad.category, ''), COUNT(*)
+		FROM rounds r
+		JOIN olx_ads ad ON ad.id = r.ad_id
+		WHERE r.winner = ? AND r.ended_at IS NOT NULL AND r.guild_id IN (
+			SELECT discord_id FROM guilds WHERE discord_id = ? OR parent_id = ?
+		)
+		GROUP BY ad.category
+	`, username, guildId, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wins := make(map[string]int)
+	for rows.Next() {
+		var (
+			category string
+			count    int
+		)
+
+		err = rows.Scan(&category, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		wins[category] += count
+	}
+
+	return wins, rows.Err()
+}
+
+func (s *SQLStore) UserGuesses(guildId int, userId int, username string) ([]GuessRecord, error) {
+	rows, err := s.conn.Query(`
+		SELECT g.round_id, g.value, ad.price, COALESCE(ad.category, ''), COALESCE(r.winner = g.username, 0)
+		FROM guesses g
+		JOIN rounds r ON r.id = g.round_id
+		JOIN olx_ads ad ON ad.id = r.ad_id
+		WHERE r.ended_at IS NOT NULL
+			AND (g.user_id = ? OR (g.user_id IS NULL AND g.username = ?))
+			AND r.guild_id IN (
+				SELECT discord_id FROM guilds WHERE discord_id = ? OR parent_id = ?
+			)
+		ORDER BY g.id
+	`, userId, username, guildId, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []GuessRecord
+	for rows.Next() {
+		var g GuessRecord
+		err = rows.Scan(&g.RoundId, &g.Value, &g.Price, &g.Category, &g.Won)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, g)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *SQLStore) UnlockBadge(guildId int, username string, badge Badge, at time.Time) (bool, error) {
+	res, err := s.conn.Exec(`
+		INSERT INTO badges (guild_id, username, badge, unlocked_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, guildId, username, string(badge), at.Unix())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (s *SQLStore) Badges(guildId int, username string) ([]UnlockedBadge, error) {
+	rows, err := s.conn.Query(`
+		SELECT badge, unlocked_at
+		FROM badges
+		WHERE guild_id = ? AND username = ?
+		ORDER BY unlocked_at, rowid
+	`, guildId, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []UnlockedBadge
+	for rows.Next() {
+		var (
+			b          UnlockedBadge
+			unlockedAt int64
+		)
+
+		err = rows.Scan(&b.Badge, &unlockedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		b.UnlockedAt = time.Unix(unlockedAt, 0)
+		res = append(res, b)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *SQLStore) ResetSeenAds(guildId int) error {
+	_, err := s.conn.Exec(`DELETE FROM seen_ads WHERE guild_id = ?`, guildId)
+	return err
+}
+
+func (s *SQLStore) AdWithPrice(price int, excludeIds []int) (olx.OLXAd, error) {
+	var (
+		ad       olx.OLXAd
+		category sql.NullString
+	)
+
+	args := []any{price}
+	placeholders := make([]string, len(excludeIds))
+	for i, id := range excludeIds {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := `
+		SELECT id, title, image, price, location, category
+		FROM olx_ads
+		WHERE price = ?`
+	if len(excludeIds) > 0 {
+		query += ` AND id NOT IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+
+	err := s.conn.QueryRow(query, args...).Scan(&ad.Id, &ad.Title, &ad.Image, &ad.Price, &ad.Location, &category)
+	ad.Category = category.String
+
+	return ad, err
+}
+
+func (s *SQLStore) DisabledCategories(guildId int) ([]string, error) {
+	rows, err := s.conn.Query(`
+		SELECT category
+		FROM disabled_categories
+		WHERE guild_id = ?`, guildId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var c string
+		err = rows.Scan(&c)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *SQLStore) SetCategoryEnabled(guildId int, category string, enabled bool) error {
+	var err error
+	if enabled {
+		_, err = s.conn.Exec(`
+			DELETE FROM disabled_categories
+			WHERE guild_id = ? AND category = ?`, guildId, category)
+	} else {
+		_, err = s.conn.Exec(`
+			INSERT OR IGNORE INTO disabled_categories (guild_id, category)
+			VALUES (?, ?)`, guildId, category)
+	}
+
+	return err
+}
@@ -0,0 +1,259 @@
+package game
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+)
+
+func TestRandomAdWithoutEligibleAds(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+
+	// guild 555 disabled no category, but nothing costs that much
+	_, err := store.RandomAd(555261239926980456, PriceRange{Min: 1_000_000})
+	if err != ErrNoAds {
+		t.Fatalf("picked ad outside of the price range\n  Want: %v\n  Got: %v\n", ErrNoAds, err)
+	}
+}
+
+// populateAds fills olx_ads the way the scraper does, with pages of 50 ads
+// of the same category in a row, and has guild 1 disable a category and see
+// the seen share of the ads
+func populateAds(tb testing.TB, count int, seen float64) *SQLStore {
+	tb.Setenv("ENV", "test")
+	db.Connect()
+
+	_, err := db.Conn.Exec(`
+		WITH RECURSIVE n(i) AS (
+			SELECT 1
+			UNION ALL
+			SELECT i + 1 FROM n WHERE i < ?
+		)
+		INSERT INTO olx_ads (id, title, price, location, image, category)
+		SELECT
+			i,
+			'Anúncio ' || i,
+			CAST(abs(random()) % 100000 AS TEXT),
+			'Recife - PE',
+			'',
+			CASE (i / 50) % 3 WHEN 0 THEN 'Móveis' WHEN 1 THEN 'Games' ELSE 'Eletro' END
+		FROM n
+	`, count)
+	if err != nil {
+		tb.Fatalf("populating ads: %v\n", err)
+	}
+
+	guildId := 1
+	_, err = db.Conn.Exec(`INSERT INTO disabled_categories (guild_id, category) VALUES (?, 'Games')`, guildId)
+	if err != nil {
+		tb.Fatalf("disabling category: %v\n", err)
+	}
+
+	_, err = db.Conn.Exec(`
+		INSERT INTO seen_ads (guild_id, ad_id)
+		SELECT ?, id FROM olx_ads WHERE abs(random()) % 100 < ?
+	`, guildId, int(seen*100))
+	if err != nil {
+		tb.Fatalf("marking ads as seen: %v\n", err)
+	}
+
+	return NewSQLStore(db.Conn)
+}
+
+// TestRandomAdUniform checks that ads right after the ones filtered
+// out aren't any more likely to be picked than the others
+// countEligibleAds counts the ads guild 1 of populateAds can get
+func countEligibleAds(t *testing.T) int {
+	var eligible int
+	err := db.Conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM olx_ads ads
+		WHERE `+eligibleAds, eligibleAdsArgs(1, PriceRange{})...).Scan(&eligible)
+	if err != nil {
+		t.Fatalf("counting eligible ads: %v\n", err)
+	}
+
+	if eligible < 10 {
+		t.Fatalf("too few eligible ads to sample: %d\n", eligible)
+	}
+
+	return eligible
+}
+
+// expectUniform checks that each of the eligible ads was picked about
+// draws times. A uniform pick keeps chi-squared around its degrees of
+// freedom, this allows for 4 standard deviations above that
+func expectUniform(t *testing.T, picks map[int]int, eligible int, draws int) {
+	if len(picks) != eligible {
+		t.Fatalf("not every eligible ad was picked\n  Want: %d\n  Got: %d\n", eligible, len(picks))
+	}
+
+	var chiSquared float64
+	for _, count := range picks {
+		chiSquared += float64((count-draws)*(count-draws)) / float64(draws)
+	}
+
+	freedom := float64(eligible - 1)
+	if limit := freedom + 4*math.Sqrt(2*freedom); chiSquared > limit {
+		t.Fatalf("ads weren't picked uniformly\n  Want: chi-squared under %.1f\n  Got: %.1f %v\n", limit, chiSquared, picks)
+	}
+}
+
+func TestRandomAdUniform(t *testing.T) {
+	// the ads after the disabled page are the ones a biased pick would favor
+	store := populateAds(t, 150, 0.5)
+	eligible := countEligibleAds(t)
+
+	candidates := randomAdCandidates
+	randomAdCandidates = 8
+	t.Cleanup(func() { randomAdCandidates = candidates })
+
+	const draws = 20
+	picks := make(map[int]int)
+	for range eligible * draws {
+		ad, err := store.RandomAd(1, PriceRange{})
+		if err != nil {
+			t.Fatalf("picking ad: %v\n", err)
+		}
+
+		picks[ad.Id]++
+	}
+
+	expectUniform(t, picks, eligible, draws)
+}
+
+func TestSampleEligibleAdsUniform(t *testing.T) {
+	store := populateAds(t, 150, 0.5)
+	eligible := countEligibleAds(t)
+
+	const draws = 20
+	picks := make(map[int]int)
+	for range eligible * draws {
+		ad, err := store.sampleEligibleAds(1, PriceRange{}, 0)
+		if err != nil {
+			t.Fatalf("picking ad: %v\n", err)
+		}
+
+		picks[ad.Id]++
+	}
+
+	expectUniform(t, picks, eligible, draws)
+}
+
+func TestSampleEligibleAdsPages(t *testing.T) {
+	// a page and a half of eligible ads
+	store := populateAds(t, 2*eligibleAdsPage+eligibleAdsPage/4, 0)
+
+	var pageEnd int
+	err := db.Conn.QueryRow(`
+		SELECT MAX(id) FROM (
+			SELECT ads.id FROM olx_ads ads WHERE `+eligibleAds+` ORDER BY ads.id LIMIT ?
+		)`, append(eligibleAdsArgs(1, PriceRange{}), eligibleAdsPage)...).Scan(&pageEnd)
+	if err != nil {
+		t.Fatalf("finding the end of the first page: %v\n", err)
+	}
+
+	var lastPage bool
+	for range 20 {
+		ad, err := store.sampleEligibleAds(1, PriceRange{}, 0)
+		if err != nil {
+			t.Fatalf("picking ad: %v\n", err)
+		}
+
+		if ad.Category == "Games" {
+			t.Fatalf("picked ad of a disabled category: %v\n", ad)
+		}
+
+		lastPage = lastPage || ad.Id > pageEnd
+	}
+
+	if !lastPage {
+		t.Fatalf("no ad of the last page was ever picked\n")
+	}
+}
+
+const benchmarkAds = 1_000_000
+
+var (
+	benchmarkOnce  sync.Once
+	benchmarkStore *SQLStore
+)
+
+// populateBenchmarkAds fills the database once for every benchmark, since
+// it takes a while with this many ads
+func populateBenchmarkAds(b *testing.B) *SQLStore {
+	benchmarkOnce.Do(func() {
+		benchmarkStore = populateAds(b, benchmarkAds, 0.9)
+	})
+
+	return benchmarkStore
+}
+
+// a guild that picked a price range and has seen most of the ads
+var benchmarkPrices = PriceRange{Min: 10, Max: 20_000}
+
+// so few ads cost this little that guilds with this range get to go
+// through every eligible ad
+var narrowBenchmarkPrices = PriceRange{Min: 1_000, Max: 1_100}
+
+func benchmarkRandomAd(b *testing.B, prices PriceRange) {
+	store := populateBenchmarkAds(b)
+	b.ResetTimer()
+
+	for range b.N {
+		_, err := store.RandomAd(1, prices)
+		if err != nil {
+			b.Fatalf("picking ad: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkRandomAd(b *testing.B) {
+	benchmarkRandomAd(b, benchmarkPrices)
+}
+
+func BenchmarkRandomAdNarrow(b *testing.B) {
+	benchmarkRandomAd(b, narrowBenchmarkPrices)
+}
+
+// benchmarkOrderByRandom is how ads used to be picked, for comparison
+func benchmarkOrderByRandom(b *testing.B, prices PriceRange) {
+	store := populateBenchmarkAds(b)
+	b.ResetTimer()
+
+	for range b.N {
+		var id int
+		err := store.conn.QueryRow(`
+			SELECT ads.id
+			FROM olx_ads ads
+			WHERE ads.category NOT IN (
+				SELECT category
+				FROM disabled_categories
+				WHERE guild_id = ?
+			)
+			AND CAST(ads.price AS INTEGER) >= ?
+			AND (? = 0 OR CAST(ads.price AS INTEGER) <= ?)
+			AND NOT EXISTS (
+				SELECT 1
+				FROM seen_ads seen
+				WHERE seen.guild_id = ? AND seen.ad_id = ads.id
+			)
+			ORDER BY random()
+			LIMIT 1
+		`, 1, prices.Min, prices.Max, prices.Max, 1).Scan(&id)
+		if err != nil {
+			b.Fatalf("picking ad: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkOrderByRandom(b *testing.B) {
+	benchmarkOrderByRandom(b, benchmarkPrices)
+}
+
+func BenchmarkOrderByRandomNarrow(b *testing.B) {
+	benchmarkOrderByRandom(b, narrowBenchmarkPrices)
+}
@@ -0,0 +1,143 @@
+package game
+
+import "slices"
+
+// GuessStyle is how a user likes to write their guesses
+type GuessStyle string
+
+const (
+	// GuessStyleThousands are guesses like 3000
+	GuessStyleThousands GuessStyle = "thousands"
+	// GuessStyleHundreds are guesses like 3200
+	GuessStyleHundreds GuessStyle = "hundreds"
+	// GuessStyleCharm are store-like prices, like 2999 or 2990
+	GuessStyleCharm GuessStyle = "charm"
+	// GuessStylePrecise is everything else, like 3150
+	GuessStylePrecise GuessStyle = "precise"
+)
+
+// guessStyles are sorted from the most to the least specific, so
+// ties go to the style that says more about the user
+var guessStyles = []GuessStyle{GuessStyleCharm, GuessStyleThousands, GuessStyleHundreds, GuessStylePrecise}
+
+func guessStyle(guess int) GuessStyle {
+	switch {
+	case guess%10 == 9 || guess%100 == 90:
+		return GuessStyleCharm
+	case guess%1000 == 0:
+		return GuessStyleThousands
+	case guess%100 == 0:
+		return GuessStyleHundreds
+	}
+
+	return GuessStylePrecise
+}
+
+// GuessRecord is a guess of a finished round along with how that round went
+type GuessRecord struct {
+	RoundId  int
+	Value    int
+	Price    int
+	Category string
+	// Won tells whether the user won the round of the guess
+	Won bool
+}
+
+// UserStats sums up how a user has been playing
+type UserStats struct {
+	Guesses int
+	Wins    int
+	// AverageError is the average PercentDiff of the guesses
+	AverageError float64
+	// MedianGuessesToWin is how many guesses the user needed in the
+	// rounds they won, 0 if they never won
+	MedianGuessesToWin float64
+	// BestCategory is where the user won the most, empty if they never won
+	BestCategory  string
+	FavoriteStyle GuessStyle
+}
+
+func userStats(guesses []GuessRecord) UserStats {
+	var stats UserStats
+	if len(guesses) == 0 {
+		return stats
+	}
+
+	stats.Guesses = len(guesses)
+
+	var totalError float64
+	styles := make(map[GuessStyle]int)
+	// how many guesses the user took in each round they won, in the order they were won
+	var wonRounds []int
+	guessesInRound := make(map[int]int)
+	winsByCategory := make(map[string]int)
+	var categories []string
+
+	for _, g := range guesses {
+		totalError += PercentDiff(g.Value, g.Price)
+		styles[guessStyle(g.Value)]++
+
+		if !g.Won {
+			continue
+		}
+
+		if _, ok := guessesInRound[g.RoundId]; !ok {
+			wonRounds = append(wonRounds, g.RoundId)
+			if winsByCategory[g.Category] == 0 {
+				categories = append(categories, g.Category)
+			}
+			winsByCategory[g.Category]++
+		}
+		guessesInRound[g.RoundId]++
+	}
+
+	stats.AverageError = totalError / float64(len(guesses))
+	stats.Wins = len(wonRounds)
+
+	if len(wonRounds) > 0 {
+		counts := make([]int, len(wonRounds))
+		for idx, id := range wonRounds {
+			counts[idx] = guessesInRound[id]
+		}
+		slices.Sort(counts)
+
+		mid := len(counts) / 2
+		if len(counts)%2 == 1 {
+			stats.MedianGuessesToWin = float64(counts[mid])
+		} else {
+			stats.MedianGuessesToWin = float64(counts[mid-1]+counts[mid]) / 2
+		}
+	}
+
+	// ties go to the category won first
+	bestWins := 0
+	for _, c := range categories {
+		if c != "" && winsByCategory[c] > bestWins {
+			stats.BestCategory = c
+			bestWins = winsByCategory[c]
+		}
+	}
+
+	for _, s := range guessStyles {
+		if styles[s] > styles[stats.FavoriteStyle] {
+			stats.FavoriteStyle = s
+		}
+	}
+
+	return stats
+}
+
+// StatsOf sums up the guesses the user made in every game of the guild
+func StatsOf(guildId int, userId int, username string) (UserStats, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return UserStats{}, ErrNoInstance
+	}
+
+	guesses, err := gi.store.UserGuesses(guildId, userId, username)
+	if err != nil {
+		return UserStats{}, err
+	}
+
+	return userStats(guesses), nil
+}
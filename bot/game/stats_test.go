@@ -0,0 +1,171 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestGuessStyle(t *testing.T) {
+	matches := map[int]GuessStyle{
+		3000:  GuessStyleThousands,
+		3200:  GuessStyleHundreds,
+		2999:  GuessStyleCharm,
+		2990:  GuessStyleCharm,
+		19:    GuessStyleCharm,
+		3150:  GuessStylePrecise,
+		12345: GuessStylePrecise,
+	}
+
+	for guess, expected := range matches {
+		if got := guessStyle(guess); got != expected {
+			t.Fatalf("style of %d mismatch\n  Want: %s\n  Got: %s\n", guess, expected, got)
+		}
+	}
+}
+
+func TestUserStats(t *testing.T) {
+	guesses := []GuessRecord{
+		{RoundId: 1, Value: 1000, Price: 2000, Category: "Games"},
+		{RoundId: 1, Value: 2000, Price: 2000, Category: "Games", Won: true},
+		{RoundId: 1, Value: 2000, Price: 2000, Category: "Games", Won: true},
+		{RoundId: 2, Value: 300, Price: 300, Category: "Móveis", Won: true},
+		{RoundId: 3, Value: 900, Price: 300, Category: "Móveis"},
+		{RoundId: 4, Value: 100, Price: 150, Category: "Roupas", Won: true},
+		{RoundId: 4, Value: 200, Price: 150, Category: "Roupas", Won: true},
+		{RoundId: 4, Value: 150, Price: 150, Category: "Roupas", Won: true},
+		{RoundId: 5, Value: 499, Price: 450, Category: "Móveis", Won: true},
+	}
+
+	got := userStats(guesses)
+
+	// PercentDiff of each guess: 66.67, 0, 0, 0, 100, 40, 28.57, 0, 10.33
+	expected := UserStats{
+		Guesses:            9,
+		Wins:               4,
+		MedianGuessesToWin: 1.5,
+		BestCategory:       "Móveis",
+		FavoriteStyle:      GuessStyleHundreds,
+	}
+	expected.AverageError = got.AverageError
+	if got != expected {
+		t.Fatalf("stats mismatch\n  Want: %+v\n  Got: %+v\n", expected, got)
+	}
+
+	if got.AverageError < 27 || got.AverageError > 28 {
+		t.Fatalf("average error out of range\n  Want: ~27.29\n  Got: %.2f\n", got.AverageError)
+	}
+
+	if got := userStats(nil); got != (UserStats{}) {
+		t.Fatalf("stats without guesses mismatch\n  Want: %+v\n  Got: %+v\n", UserStats{}, got)
+	}
+}
+
+func TestStatsOf(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200, Category: "Esportes e Lazer"},
+		{Id: 2, Title: "Poltrona em tecido", Price: 250, Category: "Móveis"},
+	}, guildId)
+
+	err := StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	ad := Ad(guildId)
+	roundId := store.rounds[len(store.rounds)-1].Id
+	for _, guess := range []int{ad.Price * 2, ad.Price} {
+		_, err = CheckGuess(42, "ana", guess, guildId)
+		if err != nil {
+			t.Fatalf("checking guess: %v\n", err)
+		}
+	}
+	waitForGuesses(t, store, roundId, 2)
+
+	// the same user, after changing their username
+	st, err := StatsOf(guildId, 42, "ana_renamed")
+	if err != nil {
+		t.Fatalf("fetching stats: %v\n", err)
+	}
+
+	if st.Guesses != 2 || st.Wins != 1 || st.MedianGuessesToWin != 2 || st.BestCategory != ad.Category {
+		t.Fatalf("stats mismatch: %+v\n", st)
+	}
+
+	// guesses in the round that's going on don't count
+	_, err = CheckGuess(42, "ana", 1, guildId)
+	if err != nil {
+		t.Fatalf("checking guess: %v\n", err)
+	}
+
+	st, err = StatsOf(guildId, 42, "ana")
+	if err != nil || st.Guesses != 2 {
+		t.Fatalf("stats counted the open round: %+v %v\n", st, err)
+	}
+}
+
+func TestSQLStoreUserGuesses(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	ad, err := store.RandomAd(guildId, PriceRange{})
+	if err != nil {
+		t.Fatalf("fetching ad: %v\n", err)
+	}
+
+	now := time.Now()
+	roundId, number, err := store.StartRound(guildId, ad.Id, now)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	guesses := []Guess{
+		// made before user ids were kept
+		{GuildId: guildId, RoundId: roundId, Value: ad.Price + 1, Username: "stats_user", CreatedAt: now},
+		{GuildId: guildId, RoundId: roundId, Value: ad.Price + 2, Username: "someone_else", UserId: 7, CreatedAt: now},
+		{GuildId: guildId, RoundId: roundId, Value: ad.Price, Username: "stats_user_renamed", UserId: 42, CreatedAt: now},
+	}
+	for _, g := range guesses {
+		err = store.AddGuess(g)
+		if err != nil {
+			t.Fatalf("adding guess: %v\n", err)
+		}
+	}
+
+	err = store.SaveRound(RoundState{
+		Id:        roundId,
+		GuildId:   guildId,
+		Number:    number,
+		Ad:        ad,
+		StartedAt: now,
+		EndedAt:   now,
+		EndReason: EndReasonWon,
+		Winner:    "stats_user_renamed",
+	})
+	if err != nil {
+		t.Fatalf("saving round: %v\n", err)
+	}
+
+	got, err := store.UserGuesses(guildId, 42, "stats_user")
+	if err != nil {
+		t.Fatalf("fetching user guesses: %v\n", err)
+	}
+
+	expected := []GuessRecord{
+		{RoundId: roundId, Value: ad.Price + 1, Price: ad.Price, Category: ad.Category},
+		{RoundId: roundId, Value: ad.Price, Price: ad.Price, Category: ad.Category, Won: true},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("user guesses mismatch\n  Want: %v\n  Got: %v\n", expected, got)
+	}
+
+	for idx := range expected {
+		if got[idx] != expected[idx] {
+			t.Fatalf("guess #%d mismatch\n  Want: %v\n  Got: %v\n", idx+1, expected[idx], got[idx])
+		}
+	}
+}
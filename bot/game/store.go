@@ -0,0 +1,162 @@
+package game
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+// Store is everything the game needs to persist. SQLStore is the one
+// used by the bot and MemoryStore is meant for tests and for running
+// the game somewhere without a database
+type Store interface {
+	Guilds() ([]GuildSettings, error)
+	AddGuild(guildId int) error
+	SaveGuild(settings GuildSettings) error
+	// RemoveGuild deletes the settings of a game and skips its current round
+	RemoveGuild(guildId int) error
+
+	// CurrentRounds are the rounds that haven't ended yet, at most one per guild
+	CurrentRounds() ([]RoundState, error)
+	// StartRound ends the guild's current round, if any, as skipped and
+	// creates a new one, marking its ad as seen by the guild. It returns
+	// the id and the number of the new round
+	StartRound(guildId int, adId int, startedAt time.Time) (int, int, error)
+	SaveRound(round RoundState) error
+	FinishedRoundsCount(guildId int) (int, error)
+	// FinishedRound gets the finished rounds of a guild from the most recent
+	// to the oldest, so offset 0 is the last round that ended
+	FinishedRound(guildId int, offset int) (ArchivedRound, error)
+
+	// AddGuess also increments the guess count of the guess' round
+	AddGuess(guess Guess) error
+
+	AddScore(guildId int, username string, points int) error
+	Ranking(guildId int) ([]AggregatedScore, error)
+	// Ratings gets the rating of every player of the guild, from the highest
+	Ratings(guildId int) ([]Rating, error)
+	// SaveRatings sets the ratings of players who just played a round, counting it for them
+	SaveRatings(guildId int, ratings map[string]float64) error
+
+	// PlaceBet takes the stake from the user's balance, failing with
+	// ErrNotEnoughCoins if they can't afford it. It returns the bet with its id
+	PlaceBet(bet Bet) (Bet, error)
+	// SettleBets saves the payout of each bet and adds it to the user's balance
+	SettleBets(bets []Bet, reason LedgerReason) error
+	Balance(guildId int, username string) (int, error)
+	// Ledger gets the latest entries of a user, from the most recent to the oldest
+	Ledger(guildId int, username string, limit int) ([]LedgerEntry, error)
+	// ClaimDaily adds amount to the balance unless the user claimed
+	// their allowance since the given time. It tells whether it did
+	ClaimDaily(guildId int, username string, amount int, since time.Time) (bool, error)
+
+	// AddTeam returns ErrTeamExists if the guild has a team with that name
+	AddTeam(guildId int, name string, roleId int) (Team, error)
+	Teams(guildId int) ([]Team, error)
+	// SetTeamMember moves a user to a team. A teamId of 0 takes them out of their team
+	SetTeamMember(guildId int, username string, teamId int) error
+	// TeamOf returns ErrNoTeam for users without a team
+	TeamOf(guildId int, username string) (Team, error)
+	AddTeamScore(guildId int, teamId int, points int) error
+	TeamRanking(guildId int) ([]TeamScore, error)
+
+	// Challenge gets the ad of the daily challenge of day, or ErrNoChallenge if there's none
+	Challenge(day time.Time) (olx.OLXAd, error)
+	// StartChallenge sets the ad of the challenge of day, marking it as seen by the challenge
+	StartChallenge(day time.Time, adId int) error
+	AddChallengeAttempt(attempt ChallengeAttempt) error
+	// ChallengeAttempts gets the attempts at the challenge of day, from the oldest
+	ChallengeAttempts(day time.Time) ([]ChallengeAttempt, error)
+
+	// StartDuel saves a duel that just started and returns its id. Its ad
+	// counts as seen by the guild, since the duelists know its price
+	StartDuel(duel Duel) (int, error)
+	FinishDuel(duel Duel) error
+	// DuelRecord only counts duels that finished
+	DuelRecord(guildId int, username string) (DuelRecord, error)
+
+	// WinsByCategory counts the rounds the user won in every game of the
+	// guild, the main one and the ones in other channels, by category
+	WinsByCategory(guildId int, username string) (map[string]int, error)
+	// UserGuesses gets the guesses the user made in the finished rounds of every
+	// game of the guild, from the oldest. Guesses without a user id go by username
+	UserGuesses(guildId int, userId int, username string) ([]GuessRecord, error)
+	// UnlockBadge gives the badge to the user, telling whether they didn't have it yet
+	UnlockBadge(guildId int, username string, badge Badge, at time.Time) (bool, error)
+	// Badges gets the badges of the user, from the first unlocked
+	Badges(guildId int, username string) ([]UnlockedBadge, error)
+
+	// RandomAd picks an ad the guild hasn't seen yet within prices, from a
+	// category the guild didn't disable. It returns ErrNoAds if there's none
+	RandomAd(guildId int, prices PriceRange) (olx.OLXAd, error)
+	// ResetSeenAds lets the guild see every ad again
+	ResetSeenAds(guildId int) error
+	// AdWithPrice finds an ad with the given price other than excludeIds
+	AdWithPrice(price int, excludeIds []int) (olx.OLXAd, error)
+
+	DisabledCategories(guildId int) ([]string, error)
+	SetCategoryEnabled(guildId int, category string, enabled bool) error
+}
+
+var ErrNoAds = errors.New("no ads to pick from")
+
+// GuildSettings is how a guild configured its game
+type GuildSettings struct {
+	GuildId      int
+	ChannelId    int
+	RoundTimeout time.Duration
+	Mode         Mode
+	MaxGuesses   int
+	PriceRange   PriceRange
+	// Tolerance is how close guesses need to be to win
+	Tolerance Tolerance
+	// SkipVotes is how many votes skipping a round takes
+	SkipVotes    int
+	TeamsEnabled bool
+	// ParentId is the guild of an extra game played in another channel, 0 for
+	// the main game of a guild. SeparateRanking keeps its scores to itself
+	ParentId        int
+	SeparateRanking bool
+	// RoundThreads opens a thread for each round, where its guesses go
+	RoundThreads bool
+	// HintRules are only the rules the guild changed from the default schedule
+	HintRules []HintRule
+}
+
+// RoundState is everything needed to restore a round as it was
+type RoundState struct {
+	Id           int
+	GuildId      int
+	Number       int
+	Ad           olx.OLXAd
+	Open         bool
+	GuessCount   int
+	Guesses      []Guess
+	Hints        []string
+	SamePrice    []int
+	ClosestGuess *ClosestGuessHint
+	StartedAt    time.Time
+	EndedAt      time.Time
+	EndReason    EndReason
+	Winner       string
+	// ThreadId is the discord thread of the round, 0 if it has none
+	ThreadId int
+	// Bets of the round, including the ones already settled
+	Bets []Bet
+}
+
+type AggregatedScore struct {
+	Username string
+	Score    int
+}
+
+func defaultGuildSettings(guildId int) GuildSettings {
+	return GuildSettings{
+		GuildId:    guildId,
+		Mode:       ModeClassic,
+		MaxGuesses: DefaultMaxGuesses,
+		Tolerance:  Tolerance{Kind: ToleranceExact},
+		SkipVotes:  DefaultSkipVotes,
+	}
+}
@@ -0,0 +1,275 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Team groups members of a guild whose points also count for the team.
+// Members with the team's role join it on their first guess, if RoleId is set
+type Team struct {
+	Id     int
+	Name   string
+	RoleId int
+}
+
+type TeamScore struct {
+	Team  string
+	Score int
+}
+
+var ErrTeamExists = errors.New("team already exists")
+var ErrNoTeam = errors.New("no such team")
+var ErrInvalidTeamName = errors.New("invalid team name")
+
+const maxTeamNameLen = 32
+
+func SetTeamsEnabled(guildId int, enabled bool) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.teamsEnabled = enabled
+	return gi.saveSettings()
+}
+
+func TeamsEnabled(guildId int) bool {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false
+	}
+	defer gi.mu.Unlock()
+
+	return gi.teamsEnabled
+}
+
+func CreateTeam(guildId int, name string, roleId int) (Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTeamNameLen {
+		return Team{}, ErrInvalidTeamName
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return Team{}, ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	team, err := gi.store.AddTeam(guildId, name, roleId)
+	if err != nil {
+		return Team{}, err
+	}
+
+	// the new team might be mapped to the role of users without a team
+	gi.teams = nil
+	return team, nil
+}
+
+func Teams(guildId int) ([]Team, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.Teams(guildId)
+}
+
+// JoinTeam moves a user to the team with the given name, leaving their old team
+func JoinTeam(guildId int, username string, name string) (Team, error) {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return Team{}, ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	teams, err := gi.store.Teams(guildId)
+	if err != nil {
+		return Team{}, err
+	}
+
+	idx := slices.IndexFunc(teams, func(t Team) bool { return strings.EqualFold(t.Name, strings.TrimSpace(name)) })
+	if idx == -1 {
+		return Team{}, ErrNoTeam
+	}
+
+	err = gi.store.SetTeamMember(guildId, username, teams[idx].Id)
+	if err != nil {
+		return Team{}, err
+	}
+
+	gi.setTeam(username, teams[idx].Name)
+	return teams[idx], nil
+}
+
+func LeaveTeam(guildId int, username string) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	err := gi.store.SetTeamMember(guildId, username, 0)
+	if err != nil {
+		return err
+	}
+
+	gi.setTeam(username, "")
+	return nil
+}
+
+func (gi *GameInstance) setTeam(username string, name string) {
+	if gi.memberTeams == nil {
+		gi.memberTeams = make(map[string]string)
+	}
+
+	gi.memberTeams[username] = name
+}
+
+// teamOf is the name of the user's team, empty if they have none. The
+// store is only asked about users the instance hasn't seen yet
+func (gi *GameInstance) teamOf(username string) (string, error) {
+	if name, ok := gi.memberTeams[username]; ok {
+		return name, nil
+	}
+
+	team, err := gi.store.TeamOf(gi.guildId, username)
+	if err != nil && !errors.Is(err, ErrNoTeam) {
+		return "", err
+	}
+
+	name := ""
+	if err == nil {
+		name = team.Name
+	}
+
+	gi.setTeam(username, name)
+	return name, nil
+}
+
+// JoinTeamByRoles puts a user that isn't in a team yet in the first team
+// mapped to one of their roles. Users that already have a team keep it.
+// It runs on every guess, so the store is only asked about users and
+// teams the instance hasn't seen yet
+func JoinTeamByRoles(guildId int, username string, roleIds []int) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	name, err := gi.teamOf(username)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		return nil
+	}
+
+	if gi.teams == nil {
+		teams, err := gi.store.Teams(guildId)
+		if err != nil {
+			return err
+		}
+
+		gi.teams = teams
+		if gi.teams == nil {
+			gi.teams = []Team{}
+		}
+	}
+
+	for _, t := range gi.teams {
+		if t.RoleId != 0 && slices.Contains(roleIds, t.RoleId) {
+			err := gi.store.SetTeamMember(guildId, username, t.Id)
+			if err != nil {
+				return err
+			}
+
+			gi.setTeam(username, t.Name)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func TeamOf(guildId int, username string) (Team, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return Team{}, ErrNoInstance
+	}
+
+	return gi.store.TeamOf(guildId, username)
+}
+
+func TeamRanking(guildId int) ([]TeamScore, error) {
+	gi, ok := instances.get(guildId)
+	if !ok {
+		return nil, ErrNoInstance
+	}
+
+	return gi.store.TeamRanking(guildId)
+}
+
+// teamName is the name of the user's team when the guild plays in teams.
+// Teams are cached by the instance of the guild, which extra games lock
+func (gi *GameInstance) teamName(username string) string {
+	if username == "" {
+		return ""
+	}
+
+	guild := gi
+	if gi.parentId != 0 {
+		parent, ok := lockInstance(gi.parentId)
+		if !ok {
+			return ""
+		}
+		defer parent.mu.Unlock()
+
+		guild = parent
+	}
+
+	if !guild.teamsEnabled {
+		return ""
+	}
+
+	name, err := guild.teamOf(username)
+	if err != nil {
+		log.Printf("fetching team of user %s in guild %d: %v\n", username, guild.guildId, err)
+		return ""
+	}
+
+	return name
+}
+
+// scoreTeams adds the points each member scored in a round to their team
+func (gi *GameInstance) scoreTeams(scores []RoundScore) {
+	points := make(map[int]int)
+	var teamIds []int
+
+	for _, sc := range scores {
+		team, err := gi.store.TeamOf(gi.guild(), sc.Username)
+		if err != nil {
+			if !errors.Is(err, ErrNoTeam) {
+				log.Printf("fetching team of user %s in guild %d: %v\n", sc.Username, gi.guildId, err)
+			}
+			continue
+		}
+
+		if _, ok := points[team.Id]; !ok {
+			teamIds = append(teamIds, team.Id)
+		}
+		points[team.Id] += sc.Points
+	}
+
+	for _, id := range teamIds {
+		err := gi.store.AddTeamScore(gi.guild(), id, points[id])
+		if err != nil {
+			log.Printf("Updating score for team %d in guild %d: %v\n", id, gi.guildId, err)
+		}
+	}
+}
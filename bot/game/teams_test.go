@@ -0,0 +1,226 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestTeams(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	_, err := CreateTeam(guildId, "Azul", 0)
+	if err != nil {
+		t.Fatalf("creating team: %v\n", err)
+	}
+
+	_, err = CreateTeam(guildId, "Vermelho", 42)
+	if err != nil {
+		t.Fatalf("creating team: %v\n", err)
+	}
+
+	_, err = CreateTeam(guildId, "Azul", 0)
+	if err != ErrTeamExists {
+		t.Fatalf("team was created twice\n  Want: %v\n  Got: %v\n", ErrTeamExists, err)
+	}
+
+	_, err = JoinTeam(guildId, "ana", "azul")
+	if err != nil {
+		t.Fatalf("joining team: %v\n", err)
+	}
+
+	_, err = JoinTeam(guildId, "ana", "Verde")
+	if err != ErrNoTeam {
+		t.Fatalf("joined a team that doesn't exist\n  Want: %v\n  Got: %v\n", ErrNoTeam, err)
+	}
+
+	// bia has the role of the red team, while ana already picked hers
+	for _, user := range []string{"ana", "bia"} {
+		err = JoinTeamByRoles(guildId, user, []int{7, 42})
+		if err != nil {
+			t.Fatalf("joining team by roles: %v\n", err)
+		}
+	}
+
+	expected := map[string]string{"ana": "Azul", "bia": "Vermelho"}
+	for user, name := range expected {
+		team, err := TeamOf(guildId, user)
+		if err != nil || team.Name != name {
+			t.Fatalf("team of %s mismatch\n  Want: %s\n  Got: %v %v\n", user, name, team, err)
+		}
+	}
+
+	err = SetTeamsEnabled(guildId, true)
+	if err != nil {
+		t.Fatalf("enabling teams: %v\n", err)
+	}
+
+	var won RoundWon
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(RoundWon); ok && e.GuildId == guildId {
+			won = e
+		}
+	})
+	defer unsubscribe()
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	CheckGuess(0, "ana", 1100, guildId)
+	CheckGuess(0, "bia", 1200, guildId)
+
+	if won.Winner != "bia" || won.Team != "Vermelho" {
+		t.Fatalf("win wasn't credited to the team\n  Got: %+v\n", won)
+	}
+
+	var ranking []TeamScore
+	eventually(t, func() bool {
+		ranking, err = TeamRanking(guildId)
+		if err != nil {
+			t.Fatalf("fetching team ranking: %v\n", err)
+		}
+
+		return len(ranking) >= 2
+	})
+	expectedRanking := []TeamScore{{Team: "Vermelho", Score: 10}, {Team: "Azul", Score: 3}}
+	for idx := range expectedRanking {
+		if ranking[idx] != expectedRanking[idx] {
+			t.Fatalf("team ranking mismatch\n  Want: %v\n  Got: %v\n", expectedRanking, ranking)
+		}
+	}
+}
+
+// teamLookups counts how often the store is asked about teams
+type teamLookups struct {
+	Store
+	count int
+}
+
+func (s *teamLookups) TeamOf(guildId int, username string) (Team, error) {
+	s.count++
+	return s.Store.TeamOf(guildId, username)
+}
+
+func (s *teamLookups) Teams(guildId int) ([]Team, error) {
+	s.count++
+	return s.Store.Teams(guildId)
+}
+
+func TestJoinTeamByRolesCache(t *testing.T) {
+	guildId := 1
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	lookups := &teamLookups{Store: store}
+	gi, _ := lockInstance(guildId)
+	gi.store = lookups
+	gi.mu.Unlock()
+
+	_, err := CreateTeam(guildId, "Azul", 42)
+	if err != nil {
+		t.Fatalf("creating team: %v\n", err)
+	}
+
+	joinByRoles := func(user string, roleIds ...int) {
+		err := JoinTeamByRoles(guildId, user, roleIds)
+		if err != nil {
+			t.Fatalf("joining team by roles: %v\n", err)
+		}
+	}
+
+	joinByRoles("ana", 42)
+	joinByRoles("bia", 7)
+	seen := lookups.count
+
+	// known users don't hit the store again, whatever their team
+	for range 10 {
+		joinByRoles("ana", 42)
+		joinByRoles("bia", 7)
+	}
+
+	if lookups.count != seen {
+		t.Fatalf("guesses of known users looked teams up\n  Want: %d\n  Got: %d\n", seen, lookups.count)
+	}
+
+	// teams created later still pick up users without a team
+	_, err = CreateTeam(guildId, "Verde", 7)
+	if err != nil {
+		t.Fatalf("creating team: %v\n", err)
+	}
+	joinByRoles("bia", 7)
+
+	// and users who leave their team join again by their roles
+	err = LeaveTeam(guildId, "ana")
+	if err != nil {
+		t.Fatalf("leaving team: %v\n", err)
+	}
+	joinByRoles("ana", 42)
+
+	expected := map[string]string{"ana": "Azul", "bia": "Verde"}
+	for user, name := range expected {
+		team, err := TeamOf(guildId, user)
+		if err != nil || team.Name != name {
+			t.Fatalf("team of %s mismatch\n  Want: %s\n  Got: %v %v\n", user, name, team, err)
+		}
+	}
+}
+
+func TestSQLStoreTeams(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	azul, err := store.AddTeam(guildId, "Azul", 42)
+	if err != nil {
+		t.Fatalf("creating team: %v\n", err)
+	}
+
+	_, err = store.AddTeam(guildId, "Azul", 0)
+	if err != ErrTeamExists {
+		t.Fatalf("team was created twice\n  Want: %v\n  Got: %v\n", ErrTeamExists, err)
+	}
+
+	_, err = store.TeamOf(guildId, "gabrieleiro")
+	if err != ErrNoTeam {
+		t.Fatalf("user without team has one\n  Want: %v\n  Got: %v\n", ErrNoTeam, err)
+	}
+
+	err = store.SetTeamMember(guildId, "gabrieleiro", azul.Id)
+	if err != nil {
+		t.Fatalf("joining team: %v\n", err)
+	}
+
+	team, err := store.TeamOf(guildId, "gabrieleiro")
+	if err != nil || team != azul {
+		t.Fatalf("team mismatch\n  Want: %v\n  Got: %v %v\n", azul, team, err)
+	}
+
+	for _, points := range []int{5, 3} {
+		err = store.AddTeamScore(guildId, azul.Id, points)
+		if err != nil {
+			t.Fatalf("adding team score: %v\n", err)
+		}
+	}
+
+	ranking, err := store.TeamRanking(guildId)
+	if err != nil || len(ranking) != 1 || ranking[0] != (TeamScore{Team: "Azul", Score: 8}) {
+		t.Fatalf("team ranking mismatch\n  Got: %v %v\n", ranking, err)
+	}
+
+	err = store.SetTeamMember(guildId, "gabrieleiro", 0)
+	if err != nil {
+		t.Fatalf("leaving team: %v\n", err)
+	}
+
+	_, err = store.TeamOf(guildId, "gabrieleiro")
+	if err != ErrNoTeam {
+		t.Fatalf("user still in team after leaving\n  Want: %v\n  Got: %v\n", ErrNoTeam, err)
+	}
+}
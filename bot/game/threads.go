@@ -0,0 +1,59 @@
+package game
+
+// SetRoundThreads turns on or off a thread for each round of the guild.
+// It takes effect from the next round on
+func SetRoundThreads(guildId int, enabled bool) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.roundThreads = enabled
+	return gi.saveSettings()
+}
+
+func RoundThreads(guildId int) bool {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return false
+	}
+	defer gi.mu.Unlock()
+
+	return gi.roundThreads
+}
+
+// SetRoundThread tells the game its round number is played in a thread.
+// Rounds that already made way for the next one are left alone
+func SetRoundThread(guildId int, number int, threadId int) error {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	if gi.round.number != number || !gi.round.endedAt.IsZero() {
+		return ErrRoundClosed
+	}
+
+	if gi.round.threadId != 0 {
+		instances.dropThread(gi.round.threadId)
+	}
+
+	gi.round.threadId = threadId
+	instances.setThread(threadId, guildId)
+	gi.saveRound()
+
+	return nil
+}
+
+// RoundThread is the thread of the guild's current round, 0 if it has none
+func RoundThread(guildId int) int {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return 0
+	}
+	defer gi.mu.Unlock()
+
+	return gi.round.threadId
+}
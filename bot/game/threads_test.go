@@ -0,0 +1,101 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestRoundThreads(t *testing.T) {
+	guildId, channelId, threadId := 1, 10, 100
+	store := newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+		{Id: 2, Title: "Poltrona em tecido", Price: 250},
+	}, guildId)
+
+	err := SetChannel(guildId, channelId)
+	if err != nil {
+		t.Fatalf("setting channel: %v\n", err)
+	}
+
+	err = SetRoundThreads(guildId, true)
+	if err != nil || !RoundThreads(guildId) {
+		t.Fatalf("enabling round threads: %v\n", err)
+	}
+
+	err = NewRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+	OpenRound(guildId)
+
+	number := RoundNumber(guildId)
+	err = SetRoundThread(guildId, number, threadId)
+	if err != nil {
+		t.Fatalf("setting round thread: %v\n", err)
+	}
+
+	if gameId, ok := GameIn(guildId, threadId); !ok || gameId != guildId {
+		t.Fatalf("game of thread mismatch\n  Want: %d\n  Got: %d %v\n", guildId, gameId, ok)
+	}
+
+	if _, ok := GameIn(2, threadId); ok {
+		t.Fatalf("found game of guild 1 from guild 2\n")
+	}
+
+	// the thread is still there after a restart
+	err = LoadGuilds(store)
+	if err != nil {
+		t.Fatalf("loading guilds: %v\n", err)
+	}
+
+	if RoundThread(guildId) != threadId {
+		t.Fatalf("round thread wasn't restored\n  Want: %d\n  Got: %d\n", threadId, RoundThread(guildId))
+	}
+
+	var won RoundWon
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(RoundWon); ok && e.GuildId == guildId {
+			won = e
+		}
+	})
+	defer unsubscribe()
+
+	CheckGuess(0, "ana", Ad(guildId).Price, guildId)
+
+	if won.Thread != threadId {
+		t.Fatalf("thread of won round mismatch\n  Want: %d\n  Got: %d\n", threadId, won.Thread)
+	}
+
+	// the win started the next round, which has no thread yet
+	if _, ok := GameIn(guildId, threadId); ok {
+		t.Fatalf("thread of a finished round still takes guesses\n")
+	}
+
+	err = SetRoundThread(guildId, number, threadId+1)
+	if err != ErrRoundClosed {
+		t.Fatalf("set thread of a finished round\n  Want: %v\n  Got: %v\n", ErrRoundClosed, err)
+	}
+}
+
+func TestSQLStoreRoundThread(t *testing.T) {
+	loadFixtureGuilds(t)
+	guildId, threadId := 555261239926980456, 100
+
+	err := SetRoundThread(guildId, RoundNumber(guildId), threadId)
+	if err != nil {
+		t.Fatalf("setting round thread: %v\n", err)
+	}
+
+	rounds, err := NewSQLStore(db.Conn).CurrentRounds()
+	if err != nil {
+		t.Fatalf("fetching current rounds: %v\n", err)
+	}
+
+	for _, r := range rounds {
+		if r.GuildId == guildId && r.ThreadId != threadId {
+			t.Fatalf("round thread wasn't saved\n  Want: %d\n  Got: %d\n", threadId, r.ThreadId)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package game
+
+import "errors"
+
+type ToleranceKind string
+
+const (
+	ToleranceExact   ToleranceKind = "exato"
+	ToleranceReais   ToleranceKind = "reais"
+	TolerancePercent ToleranceKind = "porcento"
+)
+
+// tolerances above these would let rounds be won by wild guesses
+const (
+	MaxTolerancePercent = 20
+	MaxToleranceReais   = 500
+)
+
+var ErrInvalidTolerance = errors.New("invalid win tolerance")
+
+// Tolerance is how far off a guess can be from the price and still win
+// the round. Amount is in reais or in percent of the price, depending on
+// Kind. The zero value only accepts the exact price
+type Tolerance struct {
+	Kind   ToleranceKind
+	Amount int
+}
+
+func (t Tolerance) Accepts(guess int, price int) bool {
+	diff := guess - price
+	if diff < 0 {
+		diff = -diff
+	}
+
+	switch t.Kind {
+	case ToleranceReais:
+		return diff <= t.Amount
+	case TolerancePercent:
+		return diff*100 <= price*t.Amount
+	}
+
+	return diff == 0
+}
+
+func (t Tolerance) valid() bool {
+	switch t.Kind {
+	case ToleranceExact:
+		return t.Amount == 0
+	case ToleranceReais:
+		return t.Amount > 0 && t.Amount <= MaxToleranceReais
+	case TolerancePercent:
+		return t.Amount > 0 && t.Amount <= MaxTolerancePercent
+	}
+
+	return false
+}
+
+// wins tells whether the guess wins the round. Price is right
+// guesses still can't go over the price, whatever the tolerance
+func (gi *GameInstance) wins(guess int, price int) bool {
+	if gi.mode == ModePriceIsRight && guess > price {
+		return false
+	}
+
+	return gi.tolerance.Accepts(guess, price)
+}
+
+// SetTolerance changes how close guesses need to be to win, starting with the current round
+func SetTolerance(guildId int, t Tolerance) error {
+	if !t.valid() {
+		return ErrInvalidTolerance
+	}
+
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return ErrNoInstance
+	}
+	defer gi.mu.Unlock()
+
+	gi.tolerance = t
+	return gi.saveSettings()
+}
+
+func WinTolerance(guildId int) Tolerance {
+	gi, ok := lockInstance(guildId)
+	if !ok {
+		return Tolerance{Kind: ToleranceExact}
+	}
+	defer gi.mu.Unlock()
+
+	return gi.tolerance
+}
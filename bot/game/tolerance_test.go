@@ -0,0 +1,133 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/gabrieleiro/olx-bets/bot/db"
+	"github.com/gabrieleiro/olx-bets/bot/olx"
+)
+
+func TestToleranceAccepts(t *testing.T) {
+	type TestMatch struct {
+		Tolerance Tolerance
+		Guess     int
+		Expected  bool
+	}
+
+	matches := []TestMatch{
+		{Tolerance{}, 48990, true},
+		{Tolerance{}, 49000, false},
+		{Tolerance{Kind: ToleranceExact}, 48989, false},
+		{Tolerance{Kind: ToleranceReais, Amount: 100}, 49090, true},
+		{Tolerance{Kind: ToleranceReais, Amount: 100}, 48890, true},
+		{Tolerance{Kind: ToleranceReais, Amount: 100}, 49091, false},
+		{Tolerance{Kind: TolerancePercent, Amount: 2}, 49969, true},
+		{Tolerance{Kind: TolerancePercent, Amount: 2}, 48011, true},
+		{Tolerance{Kind: TolerancePercent, Amount: 2}, 50000, false},
+	}
+
+	for _, m := range matches {
+		if got := m.Tolerance.Accepts(m.Guess, 48990); got != m.Expected {
+			t.Fatalf("%v accepting %d for R$ 48990 mismatch\n  Want: %v\n  Got: %v\n", m.Tolerance, m.Guess, m.Expected, got)
+		}
+	}
+}
+
+func TestWinTolerance(t *testing.T) {
+	guildId := 1
+	newTestStore(t, []olx.OLXAd{
+		{Id: 1, Title: "Bicicleta aro 29", Price: 1200},
+	}, guildId)
+
+	for _, invalid := range []Tolerance{
+		{Kind: ToleranceReais},
+		{Kind: ToleranceReais, Amount: MaxToleranceReais + 1},
+		{Kind: TolerancePercent, Amount: MaxTolerancePercent + 1},
+		{Kind: ToleranceExact, Amount: 10},
+		{Kind: "quase"},
+	} {
+		err := SetTolerance(guildId, invalid)
+		if err != ErrInvalidTolerance {
+			t.Fatalf("invalid tolerance %v was accepted\n  Want: %v\n  Got: %v\n", invalid, ErrInvalidTolerance, err)
+		}
+	}
+
+	tolerance := Tolerance{Kind: ToleranceReais, Amount: 50}
+	err := SetTolerance(guildId, tolerance)
+	if err != nil {
+		t.Fatalf("setting tolerance: %v\n", err)
+	}
+
+	if got := WinTolerance(guildId); got != tolerance {
+		t.Fatalf("tolerance mismatch\n  Want: %v\n  Got: %v\n", tolerance, got)
+	}
+
+	err = StartRound(guildId)
+	if err != nil {
+		t.Fatalf("starting round: %v\n", err)
+	}
+
+	var won RoundWon
+	unsubscribe := Subscribe(func(e Event) {
+		if e, ok := e.(RoundWon); ok && e.GuildId == guildId {
+			won = e
+		}
+	})
+	defer unsubscribe()
+
+	isRight, err := CheckGuess(0, "ana", 1260, guildId)
+	if err != nil || isRight {
+		t.Fatalf("guess out of the tolerance won: %v %v\n", isRight, err)
+	}
+
+	isRight, err = CheckGuess(0, "bia", 1230, guildId)
+	if err != nil || !isRight {
+		t.Fatalf("guess within the tolerance didn't win: %v %v\n", isRight, err)
+	}
+
+	if won.Winner != "bia" || won.Guess != 1230 {
+		t.Fatalf("winner mismatch\n  Want: bia with 1230\n  Got: %s with %d\n", won.Winner, won.Guess)
+	}
+}
+
+func TestWinTolerancePriceIsRight(t *testing.T) {
+	gi := &GameInstance{
+		mode:      ModePriceIsRight,
+		tolerance: Tolerance{Kind: TolerancePercent, Amount: 10},
+	}
+
+	if gi.wins(1050, 1000) {
+		t.Fatalf("guess over the price won a price is right round\n")
+	}
+
+	if !gi.wins(950, 1000) {
+		t.Fatalf("guess within the tolerance didn't win a price is right round\n")
+	}
+}
+
+func TestSQLStoreTolerance(t *testing.T) {
+	loadFixtureGuilds(t)
+	store := NewSQLStore(db.Conn)
+	guildId := 555261239926980456
+
+	err := SetTolerance(guildId, Tolerance{Kind: TolerancePercent, Amount: 5})
+	if err != nil {
+		t.Fatalf("setting tolerance: %v\n", err)
+	}
+
+	guilds, err := store.Guilds()
+	if err != nil {
+		t.Fatalf("fetching guilds: %v\n", err)
+	}
+
+	for _, g := range guilds {
+		expected := Tolerance{Kind: ToleranceExact}
+		if g.GuildId == guildId {
+			expected = Tolerance{Kind: TolerancePercent, Amount: 5}
+		}
+
+		if g.Tolerance != expected {
+			t.Fatalf("tolerance of guild %d mismatch\n  Want: %v\n  Got: %v\n", g.GuildId, expected, g.Tolerance)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/gabrieleiro/olx-bets/bot/db"
@@ -25,13 +26,26 @@ func main() {
 	}
 
 	db.Connect()
-	game.LoadGuilds()
 
 	session := discord.Session()
 
+	game.Subscribe(discord.GameEvent)
+	err = game.LoadGuilds(game.NewSQLStore(db.Conn))
+	if err != nil {
+		log.Fatalf("loading guilds: %v", err)
+	}
+
 	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := discord.Handlers[i.ApplicationCommandData().Name]; ok {
-			h(s, i)
+		switch i.Type {
+		case discordgo.InteractionApplicationCommand:
+			if h, ok := discord.Handlers[i.ApplicationCommandData().Name]; ok {
+				h(s, i)
+			}
+		case discordgo.InteractionMessageComponent:
+			action, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+			if h, ok := discord.ComponentHandlers[action]; ok {
+				h(s, i)
+			}
 		}
 	})
 
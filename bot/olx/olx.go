@@ -6,6 +6,7 @@ type OLXAd struct {
 	Image    string
 	Price    int
 	Location string
+	Category string
 }
 
 var Categories = []string{
@@ -0,0 +1,2 @@
+ALTER TABLE guilds ADD COLUMN round_timeout INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rounds ADD COLUMN started_at INTEGER;
@@ -0,0 +1 @@
+ALTER TABLE scores ADD COLUMN points INTEGER NOT NULL DEFAULT 1;
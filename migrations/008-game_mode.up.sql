@@ -0,0 +1,2 @@
+ALTER TABLE guilds ADD COLUMN game_mode TEXT NOT NULL DEFAULT 'classico';
+ALTER TABLE guilds ADD COLUMN max_guesses INTEGER NOT NULL DEFAULT 3;
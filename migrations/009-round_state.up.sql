@@ -0,0 +1,15 @@
+ALTER TABLE rounds ADD COLUMN opened INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rounds ADD COLUMN number INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE rounds ADD COLUMN guess_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rounds ADD COLUMN hints TEXT NOT NULL DEFAULT '';
+ALTER TABLE rounds ADD COLUMN same_price TEXT NOT NULL DEFAULT '';
+ALTER TABLE rounds ADD COLUMN closest_username TEXT;
+ALTER TABLE rounds ADD COLUMN closest_guess INTEGER;
+
+-- rounds used to be reopened on every startup
+UPDATE rounds SET opened = 1;
+UPDATE rounds SET guess_count = (
+    SELECT COUNT(*)
+    FROM guesses
+    WHERE guesses.guild_id = rounds.guild_id
+);
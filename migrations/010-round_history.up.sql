@@ -0,0 +1,14 @@
+ALTER TABLE rounds ADD COLUMN ended_at INTEGER;
+ALTER TABLE rounds ADD COLUMN end_reason TEXT;
+ALTER TABLE rounds ADD COLUMN winner TEXT;
+ALTER TABLE guesses ADD COLUMN round_id INTEGER;
+ALTER TABLE guesses ADD COLUMN created_at INTEGER;
+
+UPDATE guesses SET round_id = (
+    SELECT id
+    FROM rounds
+    WHERE rounds.guild_id = guesses.guild_id
+);
+
+CREATE INDEX IF NOT EXISTS rounds_guild_id ON rounds(guild_id, ended_at);
+CREATE INDEX IF NOT EXISTS guesses_round_id ON guesses(round_id);
@@ -0,0 +1,11 @@
+-- guilds without a rule for a kind of hint use the default schedule.
+-- Rules that fire neither after some guesses nor after some time are off
+CREATE TABLE hint_rules (
+    guild_id INTEGER NOT NULL,
+    kind     TEXT NOT NULL,
+    guesses  INTEGER NOT NULL DEFAULT 0,
+    every    INTEGER NOT NULL DEFAULT 0,
+    elapsed  INTEGER NOT NULL DEFAULT 0,
+    chance   INTEGER NOT NULL DEFAULT 100,
+    PRIMARY KEY (guild_id, kind)
+);
@@ -0,0 +1,3 @@
+-- a max_price of 0 means there's no upper limit
+ALTER TABLE guilds ADD COLUMN min_price INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE guilds ADD COLUMN max_price INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+CREATE TABLE seen_ads (
+    guild_id INTEGER NOT NULL,
+    ad_id    INTEGER NOT NULL,
+    PRIMARY KEY (guild_id, ad_id)
+);
+
+INSERT OR IGNORE INTO seen_ads (guild_id, ad_id)
+SELECT guild_id, ad_id FROM rounds;
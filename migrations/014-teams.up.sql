@@ -0,0 +1,27 @@
+ALTER TABLE guilds ADD COLUMN teams_enabled INTEGER NOT NULL DEFAULT 0;
+
+-- members of a guild with the team's role join it on their first guess
+CREATE TABLE teams (
+    id       INTEGER PRIMARY KEY,
+    guild_id INTEGER NOT NULL,
+    name     TEXT NOT NULL,
+    role_id  INTEGER,
+    UNIQUE (guild_id, name)
+);
+
+CREATE TABLE team_members (
+    guild_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    team_id  INTEGER NOT NULL,
+    PRIMARY KEY (guild_id, username)
+);
+
+CREATE TABLE team_scores (
+    id         INTEGER PRIMARY KEY,
+    guild_id   INTEGER NOT NULL,
+    team_id    INTEGER NOT NULL,
+    points     INTEGER NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS team_scores_guild_id ON team_scores(guild_id);
@@ -0,0 +1,25 @@
+-- payout stays NULL until the bet's round ends
+CREATE TABLE bets (
+    id         INTEGER PRIMARY KEY,
+    guild_id   INTEGER NOT NULL,
+    round_id   INTEGER NOT NULL,
+    username   TEXT NOT NULL,
+    guess      INTEGER NOT NULL,
+    stake      INTEGER NOT NULL,
+    payout     INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+-- balances are the sum of a user's entries, so every coin can be accounted for
+CREATE TABLE coin_ledger (
+    id         INTEGER PRIMARY KEY,
+    guild_id   INTEGER NOT NULL,
+    username   TEXT NOT NULL,
+    amount     INTEGER NOT NULL,
+    reason     TEXT NOT NULL,
+    bet_id     INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS bets_round_id ON bets(round_id);
+CREATE INDEX IF NOT EXISTS coin_ledger_user ON coin_ledger(guild_id, username);
@@ -0,0 +1,16 @@
+-- days are dates in São Paulo, formatted as YYYY-MM-DD
+CREATE TABLE daily_challenges (
+    day   TEXT PRIMARY KEY,
+    ad_id INTEGER NOT NULL
+);
+
+CREATE TABLE challenge_attempts (
+    id         INTEGER PRIMARY KEY,
+    day        TEXT NOT NULL,
+    guild_id   INTEGER NOT NULL,
+    username   TEXT NOT NULL,
+    guess      INTEGER NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS challenge_attempts_day ON challenge_attempts(day);
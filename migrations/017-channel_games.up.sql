@@ -0,0 +1,4 @@
+-- guilds can run extra games in other channels. Each is a row of its own,
+-- keyed by the id of its channel, with parent_id pointing to its guild
+ALTER TABLE guilds ADD COLUMN parent_id INTEGER;
+ALTER TABLE guilds ADD COLUMN separate_ranking INTEGER NOT NULL DEFAULT 0;
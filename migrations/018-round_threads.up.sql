@@ -0,0 +1,3 @@
+-- guilds with round_threads on get a thread for each round, where guesses go
+ALTER TABLE guilds ADD COLUMN round_threads INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE rounds ADD COLUMN thread_id INTEGER;
@@ -0,0 +1,17 @@
+-- guesses are the closest of each player, NULL if they didn't guess.
+-- Draws have no winner, and duels cut short by a restart have no ended_at
+CREATE TABLE duels (
+    id               INTEGER PRIMARY KEY,
+    guild_id         INTEGER NOT NULL,
+    challenger       TEXT NOT NULL,
+    opponent         TEXT NOT NULL,
+    ad_id            INTEGER NOT NULL,
+    challenger_guess INTEGER,
+    opponent_guess   INTEGER,
+    winner           TEXT,
+    started_at       INTEGER NOT NULL,
+    ended_at         INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS duels_challenger ON duels(guild_id, challenger);
+CREATE INDEX IF NOT EXISTS duels_opponent ON duels(guild_id, opponent);
@@ -0,0 +1,8 @@
+-- badges are unlocked once per user in each guild
+CREATE TABLE badges (
+    guild_id    INTEGER NOT NULL,
+    username    TEXT NOT NULL,
+    badge       TEXT NOT NULL,
+    unlocked_at INTEGER NOT NULL,
+    PRIMARY KEY (guild_id, username, badge)
+);
@@ -0,0 +1,8 @@
+-- ratings go up and down after each round the player guessed in
+CREATE TABLE ratings (
+    guild_id INTEGER NOT NULL,
+    username TEXT NOT NULL,
+    rating   REAL NOT NULL,
+    rounds   INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (guild_id, username)
+);
@@ -0,0 +1,6 @@
+-- usernames can change, so guesses are also kept by the discord id of
+-- the user. Guesses made before this only have the username
+ALTER TABLE guesses ADD COLUMN user_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS guesses_user_id ON guesses(user_id);
+CREATE INDEX IF NOT EXISTS guesses_username ON guesses(username);
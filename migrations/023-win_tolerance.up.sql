@@ -0,0 +1,4 @@
+-- how far off a guess can be and still win: the exact price, up
+-- to win_tolerance_amount reais or up to win_tolerance_amount percent
+ALTER TABLE guilds ADD COLUMN win_tolerance TEXT NOT NULL DEFAULT 'exato';
+ALTER TABLE guilds ADD COLUMN win_tolerance_amount INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- how many votes /pular needs to skip a round, admins skip right away
+ALTER TABLE guilds ADD COLUMN skip_votes INTEGER NOT NULL DEFAULT 3;